@@ -3,12 +3,13 @@ module github.com/AbdKaan/chirpy
 go 1.23.1
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/crypto v0.29.0
+	golang.org/x/text v0.20.0
 )
 
-require (
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-)
+require golang.org/x/sys v0.27.0 // indirect
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+)
+
+const recoveryCodeCount = 10
+
+// maxMFAAttempts is how many wrong codes an mfa token survives.
+const maxMFAAttempts = 5
+
+// mfaTokenLifetime is how long after the password the second factor can
+// be entered.
+const mfaTokenLifetime = 5 * time.Minute
+
+// useTOTPCode checks a code from the user's authenticator and uses it up,
+// reporting false when it's wrong or was already used. A code is only good
+// once, even within its own time step.
+func (cfg *apiConfig) useTOTPCode(ctx context.Context, user database.User, code string) (bool, error) {
+	secret, err := auth.Decrypt(user.TotpSecret.String, cfg.totpKey)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	_, err = cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// handlerEnrollTOTP starts enrolling the user in 2FA. It takes the password
+// too, so a stolen access token can't be used to put the account behind an
+// authenticator of the thief's.
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, ok := cfg.authenticateWithPassword(w, r, params.Password)
+	if !ok {
+		return
+	}
+
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	secret, err := auth.MakeTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create secret", err)
+		return
+	}
+
+	encryptedSecret, err := auth.Encrypt(secret, cfg.totpKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't encrypt secret", err)
+		return
+	}
+
+	// the secret stays pending until a code generated from it is confirmed
+	_, err = cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: encryptedSecret, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save secret", err)
+		return
+	}
+
+	type response struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI("Chirpy", user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication hasn't been enrolled", nil)
+		return
+	}
+
+	ok, err := cfg.useTOTPCode(r.Context(), user, params.Code)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check code", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Incorrect code", nil)
+		return
+	}
+
+	_, err = cfg.db.EnableTOTP(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+
+	recoveryCodes, err := cfg.replaceRecoveryCodes(r, user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// replaceRecoveryCodes throws away the user's recovery codes and returns a
+// fresh set. Only their hashes are stored, so this is the one time the
+// codes can be shown.
+func (cfg *apiConfig) replaceRecoveryCodes(r *http.Request, user database.User) ([]string, error) {
+	err := cfg.db.DeleteRecoveryCodesOfUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		err = cfg.db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+func (cfg *apiConfig) handlerLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	userId, tokenID, err := auth.ValidateMFAToken(params.MFAToken, cfg.jwtKeys)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, tokenErrorMessage(err), err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get user", err)
+		return
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication isn't enabled", nil)
+		return
+	}
+
+	ip := clientIP(r)
+	wait, err := cfg.loginWait(r.Context(), user.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check failed logins", err)
+		return
+	}
+	if wait > 0 {
+		respondWithLoginWait(w, wait)
+		return
+	}
+
+	// an mfa token is only good for a few guesses, after that the password
+	// has to be entered again
+	failures, err := cfg.db.CountMFAFailuresOfToken(r.Context(), tokenID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check failed logins", err)
+		return
+	}
+	if failures >= maxMFAAttempts {
+		respondWithError(w, http.StatusUnauthorized, "Too many incorrect codes, log in again", nil)
+		return
+	}
+
+	var ok bool
+	if params.RecoveryCode != "" {
+		_, err = cfg.db.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(params.RecoveryCode),
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check recovery code", err)
+			return
+		}
+		ok = err == nil
+	} else {
+		ok, err = cfg.useTOTPCode(r.Context(), user, params.Code)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check code", err)
+			return
+		}
+	}
+
+	if !ok {
+		if err := cfg.db.CreateMFAFailure(r.Context(), tokenID); err != nil {
+			log.Printf("Couldn't record failed code: %s", err)
+		}
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, ip, &user); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
+		respondWithError(w, http.StatusUnauthorized, "Incorrect or already used code", nil)
+		return
+	}
+
+	err = cfg.clearLoginFailures(r.Context(), user.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't clear failed logins", err)
+		return
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
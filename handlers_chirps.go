@@ -1,19 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/chirptext"
 	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/entitlements"
 	"github.com/google/uuid"
 )
 
+const (
+	visibilityPublic    = "public"
+	visibilityFollowers = "followers"
+	visibilityMentioned = "mentioned"
+)
+
 func (cfg *apiConfig) handlerCreatePost(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body    string `json:"body"`
-		User_ID string `json:"user_id"`
+		Body       string `json:"body"`
+		User_ID    string `json:"user_id"`
+		Visibility string `json:"visibility"`
+		ExpiresIn  string `json:"expires_in"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -30,34 +43,105 @@ func (cfg *apiConfig) handlerCreatePost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(bearerToken, cfg.secret)
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get user", err)
+		return
+	}
+	if !cfg.unverified.Allows(user, entitlements.ActionChirp) {
+		respondWithError(w, http.StatusForbidden, "Verify your email before chirping", nil)
+		return
+	}
+	limits := cfg.tiers.For(user)
+
+	body, err := chirptext.Validate(params.Body, limits.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, chirpTextErrorMessage(err), nil)
+		return
+	}
+
+	recentPosts, err := cfg.db.CountPostsOfAuthorInLastHour(r.Context(), userId)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate user ID", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count recent chirps", err)
+		return
+	}
+	if recentPosts >= int64(limits.ChirpsPerHour) {
+		respondWithError(w, http.StatusTooManyRequests, "Hourly chirp limit reached", nil)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	if params.Visibility == "" {
+		params.Visibility = visibilityPublic
+	}
+	switch params.Visibility {
+	case visibilityPublic, visibilityFollowers, visibilityMentioned:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Visibility must be public, followers or mentioned", nil)
 		return
 	}
 
-	post, err := cfg.db.CreatePost(r.Context(), database.CreatePostParams{
-		Body:   params.Body,
-		UserID: userId,
+	// a chirp without expires_in never expires
+	expiresIn := sql.NullFloat64{}
+	if params.ExpiresIn != "" {
+		duration, err := time.ParseDuration(params.ExpiresIn)
+		if err != nil || duration <= 0 {
+			respondWithError(w, http.StatusBadRequest, "expires_in must be a positive duration like \"24h\"", err)
+			return
+		}
+		expiresIn = sql.NullFloat64{Float64: duration.Seconds(), Valid: true}
+	}
+
+	// the mentions decide who sees a "mentioned" chirp, so it's only stored
+	// along with them
+	var post database.Post
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		post, err = q.CreatePost(r.Context(), database.CreatePostParams{
+			Body:             body,
+			UserID:           userId,
+			Visibility:       params.Visibility,
+			ExpiresInSeconds: expiresIn,
+		})
+		if err != nil {
+			return err
+		}
+		return saveMentions(r.Context(), q, post)
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create posts", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, Post{
-		ID:        post.ID,
-		CreatedAt: post.CreatedAt,
-		UpdatedAt: post.UpdatedAt,
-		Body:      cencorProfane(post.Body),
-		User_ID:   post.UserID.String(),
-	})
+	respondWithJSON(w, http.StatusCreated, databasePostToPost(post))
+}
+
+// saveMentions stores every mention in the chirp that resolves to an
+// existing user, they decide who can see a "mentioned" chirp.
+func saveMentions(ctx context.Context, q *database.Queries, post database.Post) error {
+	for _, email := range extractMentions(post.Body) {
+		mentioned, err := q.GetUserWithEmail(ctx, email)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = q.CreatePostMention(ctx, database.CreatePostMentionParams{
+			PostID: post.ID,
+			UserID: mentioned.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (cfg *apiConfig) handlerGetPost(w http.ResponseWriter, r *http.Request) {
@@ -67,25 +151,28 @@ func (cfg *apiConfig) handlerGetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := cfg.db.GetPost(r.Context(), chirpID)
+	viewerID := cfg.viewerID(r)
+
+	// chirps the viewer isn't allowed to see are reported as missing so
+	// their existence isn't leaked
+	post, err := cfg.db.GetVisiblePost(r.Context(), database.GetVisiblePostParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't get post", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Post{
-		ID:        post.ID,
-		CreatedAt: post.CreatedAt,
-		UpdatedAt: post.UpdatedAt,
-		Body:      cencorProfane(post.Body),
-		User_ID:   post.UserID.String(),
-	})
+	respondWithJSON(w, http.StatusOK, databasePostToPost(post))
 }
 
 func (cfg *apiConfig) handlerGetPosts(w http.ResponseWriter, r *http.Request) {
 	authorID := r.URL.Query().Get("author_id")
 	orderBy := r.URL.Query().Get("sort")
 
+	viewerID := cfg.viewerID(r)
+
 	var posts []database.Post
 	var err error
 	if authorID != "" {
@@ -95,13 +182,16 @@ func (cfg *apiConfig) handlerGetPosts(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		posts, err = cfg.db.GetPostsOfAuthor(r.Context(), authorIDuuid)
+		posts, err = cfg.db.GetPostsOfAuthor(r.Context(), database.GetPostsOfAuthorParams{
+			UserID:   authorIDuuid,
+			ViewerID: viewerID,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't get posts", err)
 			return
 		}
 	} else {
-		posts, err = cfg.db.GetPosts(r.Context())
+		posts, err = cfg.db.GetPosts(r.Context(), viewerID)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Couldn't get posts", err)
 			return
@@ -110,13 +200,7 @@ func (cfg *apiConfig) handlerGetPosts(w http.ResponseWriter, r *http.Request) {
 
 	var postsArr []Post
 	for _, post := range posts {
-		postsArr = append(postsArr, Post{
-			ID:        post.ID,
-			CreatedAt: post.CreatedAt,
-			UpdatedAt: post.UpdatedAt,
-			Body:      cencorProfane(post.Body),
-			User_ID:   post.UserID.String(),
-		})
+		postsArr = append(postsArr, databasePostToPost(post))
 	}
 
 	if orderBy == "desc" {
@@ -126,9 +210,42 @@ func (cfg *apiConfig) handlerGetPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if authorID != "" {
+		// pinned chirps lead a profile, most recently pinned first
+		sort.SliceStable(postsArr, func(i, j int) bool {
+			if postsArr[i].PinnedAt == nil || postsArr[j].PinnedAt == nil {
+				return postsArr[i].PinnedAt != nil && postsArr[j].PinnedAt == nil
+			}
+			return postsArr[i].PinnedAt.After(*postsArr[j].PinnedAt)
+		})
+	}
+
 	respondWithJSON(w, http.StatusOK, postsArr)
 }
 
+func databasePostToPost(post database.Post) Post {
+	var pinnedAt *time.Time
+	if post.PinnedAt.Valid {
+		pinnedAt = &post.PinnedAt.Time
+	}
+
+	var expiresAt *time.Time
+	if post.ExpiresAt.Valid {
+		expiresAt = &post.ExpiresAt.Time
+	}
+
+	return Post{
+		ID:         post.ID,
+		CreatedAt:  post.CreatedAt,
+		UpdatedAt:  post.UpdatedAt,
+		Body:       cencorProfane(post.Body),
+		User_ID:    post.UserID.String(),
+		Visibility: post.Visibility,
+		PinnedAt:   pinnedAt,
+		ExpiresAt:  expiresAt,
+	}
+}
+
 func (cfg *apiConfig) handlerDeletePost(w http.ResponseWriter, r *http.Request) {
 	bearerToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -136,9 +253,9 @@ func (cfg *apiConfig) handlerDeletePost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(bearerToken, cfg.secret)
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		w.WriteHeader(tokenErrorStatus(err))
 		return
 	}
 
@@ -148,7 +265,7 @@ func (cfg *apiConfig) handlerDeletePost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	post, err := cfg.db.GetPost(r.Context(), chirpID)
+	post, err := cfg.db.GetUnexpiredPost(r.Context(), chirpID)
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+func (cfg *apiConfig) handlerChangeEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, ok := cfg.authenticateWithPassword(w, r, params.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	user, ok = cfg.changeEmail(w, r, user, params.Email)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseUserToUser(user))
+}
+
+func (cfg *apiConfig) handlerChangePassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, ok := cfg.authenticateWithPassword(w, r, params.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	hashedPw, ok := cfg.hashNewPassword(w, params.NewPassword)
+	if !ok {
+		return
+	}
+
+	cfg.changePassword(w, r, user, hashedPw)
+}
+
+// authenticateWithPassword gets the user of a login's access token and
+// checks password is their current one, responding with an error if not.
+// Wrong passwords are throttled like failed logins, so a stolen access token
+// can't be used to guess the password. They get 403 rather than 401, the
+// token itself is fine.
+func (cfg *apiConfig) authenticateWithPassword(w http.ResponseWriter, r *http.Request, password string) (database.User, bool) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return database.User{}, false
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return database.User{}, false
+	}
+
+	ip := clientIP(r)
+	wait, err := cfg.loginWait(r.Context(), user.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check failed logins", err)
+		return database.User{}, false
+	}
+	if wait > 0 {
+		respondWithLoginWait(w, wait)
+		return database.User{}, false
+	}
+
+	_, err = cfg.passwords.Check(password, user.HashedPassword)
+	if err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, ip, &user); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
+		respondWithError(w, http.StatusForbidden, "Current password is incorrect", err)
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+// changeEmail moves user to email, which has to be verified again,
+// responding with an error if it can't.
+func (cfg *apiConfig) changeEmail(w http.ResponseWriter, r *http.Request, user database.User, email string) (database.User, bool) {
+	err := validateEmail(email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Email is invalid", err)
+		return database.User{}, false
+	}
+
+	if email == user.Email {
+		return user, true
+	}
+
+	user, err = cfg.db.UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
+		ID:    user.ID,
+		Email: email,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already in use", err)
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update email", err)
+		return database.User{}, false
+	}
+
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		log.Printf("Couldn't send verification email to %s: %s", user.ID, err)
+	}
+
+	return user, true
+}
+
+// hashNewPassword checks a new password against the policy and hashes it,
+// responding with an error if it can't. Doing both before anything is
+// changed keeps an update from being half applied.
+func (cfg *apiConfig) hashNewPassword(w http.ResponseWriter, password string) (string, bool) {
+	err := cfg.passwordPolicy.Validate(password)
+	if err != nil {
+		respondWithPasswordError(w, err)
+		return "", false
+	}
+
+	hashedPw, err := cfg.passwords.Hash(password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		return "", false
+	}
+
+	return hashedPw, true
+}
+
+// changePassword sets a new password hash from hashNewPassword. Every other
+// session is signed out, so the user gets a new session along with their
+// updated profile.
+func (cfg *apiConfig) changePassword(w http.ResponseWriter, r *http.Request, user database.User, hashedPw string) {
+	_, err := cfg.db.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hashedPw,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update password", err)
+		return
+	}
+
+	// a reset link sent for the old password shouldn't undo this one
+	err = cfg.db.UsePasswordResetTokensOfUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke reset tokens", err)
+		return
+	}
+
+	err = cfg.revokeAllSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	// reread for the new token version
+	user, err = cfg.db.GetUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+// validateEmail checks email is a bare address, with no display name or
+// anything else that would end up in the headers of mail sent to it.
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return err
+	}
+	if address.Address != email {
+		return errors.New("email isn't a bare address")
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// of a unique column.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
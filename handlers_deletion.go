@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/mailer"
+)
+
+// handlerDeleteAccount schedules the user's account for deletion once the
+// grace period is over. Until then, logging in cancels it.
+func (cfg *apiConfig) handlerDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, ok := cfg.authenticateWithPassword(w, r, params.Password)
+	if !ok {
+		return
+	}
+
+	// with 2FA on, the second factor is needed too
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.useTOTPCode(r.Context(), user, params.Code)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check code", err)
+			return
+		}
+		if !ok {
+			if err := cfg.recordLoginFailure(r.Context(), user.Email, clientIP(r), &user); err != nil {
+				log.Printf("Couldn't record failed login: %s", err)
+			}
+			respondWithError(w, http.StatusForbidden, "Incorrect or already used code", nil)
+			return
+		}
+	}
+
+	user, err = cfg.db.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID:           user.ID,
+		GraceSeconds: cfg.deletionGracePeriod.Seconds(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule deletion", err)
+		return
+	}
+
+	err = cfg.revokeAllSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	err = cfg.sendMail(r.Context(), user.Email, mailer.TemplateAccountDeletion, map[string]any{
+		"DeleteAt": user.DeletionScheduledAt.Time,
+	})
+	if err != nil {
+		log.Printf("Couldn't mail deletion notice to %s: %s", user.ID, err)
+	}
+
+	type response struct {
+		DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		DeletionScheduledAt: user.DeletionScheduledAt.Time,
+	})
+}
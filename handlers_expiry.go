@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// getOwnPost fetches a chirp that hasn't expired yet and belongs to userId,
+// responding with the right error when it can't.
+func (cfg *apiConfig) getOwnPost(w http.ResponseWriter, r *http.Request, userId uuid.UUID) (database.Post, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse chirp ID", err)
+		return database.Post{}, false
+	}
+
+	post, err := cfg.db.GetUnexpiredPost(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Couldn't get post", err)
+		return database.Post{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get post", err)
+		return database.Post{}, false
+	}
+
+	if post.UserID != userId {
+		respondWithError(w, http.StatusForbidden, "You can only change your own chirps", nil)
+		return database.Post{}, false
+	}
+
+	return post, true
+}
+
+func (cfg *apiConfig) handlerSetPostExpiry(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ExpiresIn string `json:"expires_in"`
+	}
+
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	duration, err := time.ParseDuration(params.ExpiresIn)
+	if err != nil || duration <= 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in must be a positive duration like \"24h\"", err)
+		return
+	}
+
+	post, ok := cfg.getOwnPost(w, r, userId)
+	if !ok {
+		return
+	}
+
+	post, err = cfg.db.SetPostExpiry(r.Context(), database.SetPostExpiryParams{
+		ExpiresInSeconds: duration.Seconds(),
+		ID:               post.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't set chirp expiry", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databasePostToPost(post))
+}
+
+func (cfg *apiConfig) handlerClearPostExpiry(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	post, ok := cfg.getOwnPost(w, r, userId)
+	if !ok {
+		return
+	}
+
+	post, err = cfg.db.ClearPostExpiry(r.Context(), post.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't cancel chirp expiry", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databasePostToPost(post))
+}
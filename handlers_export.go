@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/userarchive"
+	"github.com/google/uuid"
+)
+
+const (
+	// exportDone is the status of a job whose archive is ready, the others
+	// are pending, running and failed.
+	exportDone = "done"
+
+	// exportLifetime is how long a finished archive can be downloaded.
+	exportLifetime = 7 * 24 * time.Hour
+	// downloadLinkLifetime is how long a download link works, a fresh one
+	// comes with every status check.
+	downloadLinkLifetime = 15 * time.Minute
+)
+
+// handlerStartExport starts an export of everything stored about the
+// user, unless one is already underway. Archives are built in the
+// background, clients poll the status until it's done and then follow the
+// download link.
+func (cfg *apiConfig) handlerStartExport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	// a finished export doesn't stop a new one, the user may have changed
+	// things since
+	job, err := cfg.db.GetUnfinishedExportJobOfUser(r.Context(), userId)
+	if err == sql.ErrNoRows {
+		job, err = cfg.db.CreateExportJob(r.Context(), userId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start export", err)
+		return
+	}
+
+	cfg.respondWithExportJob(w, job)
+}
+
+// handlerGetLatestExport returns the user's latest export that hasn't
+// failed or expired.
+func (cfg *apiConfig) handlerGetLatestExport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	job, err := cfg.db.GetLatestExportJobOfUser(r.Context(), userId)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "No export yet, start one first", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get export", err)
+		return
+	}
+
+	cfg.respondWithExportJob(w, job)
+}
+
+func (cfg *apiConfig) handlerGetExport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse export ID", err)
+		return
+	}
+
+	job, err := cfg.db.GetExportJob(r.Context(), database.GetExportJobParams{
+		ID:     exportID,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't get export", err)
+		return
+	}
+
+	cfg.respondWithExportJob(w, job)
+}
+
+// handlerDownloadExport serves a finished archive. The token in the link
+// stands in for the bearer token a browser can't send.
+func (cfg *apiConfig) handlerDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse export ID", err)
+		return
+	}
+
+	tokenID, err := auth.ValidateDownloadToken(r.URL.Query().Get("token"), cfg.jwtKeys)
+	if err != nil || tokenID != exportID {
+		respondWithError(w, http.StatusUnauthorized, "Download link is invalid or expired", err)
+		return
+	}
+
+	archive, err := cfg.db.GetExportArchive(r.Context(), exportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Export is gone or not ready yet", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "chirpy-export-"+exportID.String()+".zip"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+func (cfg *apiConfig) respondWithExportJob(w http.ResponseWriter, job database.ExportJob) {
+	export := ExportJob{
+		ID:          job.ID,
+		CreatedAt:   job.CreatedAt,
+		Status:      job.Status,
+		Error:       job.Error.String,
+		CompletedAt: nullTimePtr(job.CompletedAt),
+		ExpiresAt:   nullTimePtr(job.ExpiresAt),
+	}
+
+	if job.Status != exportDone {
+		respondWithJSON(w, http.StatusAccepted, export)
+		return
+	}
+
+	token, err := auth.MakeDownloadToken(job.ID, cfg.jwtKeys, downloadLinkLifetime)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create download link", err)
+		return
+	}
+	export.DownloadURL = cfg.baseURL + "/api/exports/" + job.ID.String() + "/download?token=" + url.QueryEscape(token)
+
+	respondWithJSON(w, http.StatusOK, export)
+}
+
+// buildExport collects everything stored about the user into an archive.
+func (cfg *apiConfig) buildExport(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	user, err := cfg.db.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := cfg.db.GetAllPostsOfAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	following, err := cfg.db.GetFolloweesOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	followers, err := cfg.db.GetFollowersOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := cfg.db.GetSessionsOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	pats, err := cfg.db.GetPersonalAccessTokensOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := cfg.db.GetUserIdentitiesOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := cfg.db.GetOAuthGrantsOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := cfg.db.GetOAuthClientsOfOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	imports, err := cfg.db.GetImportJobsOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mentioned, err := cfg.db.GetMentionsInPostsOfAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := cfg.db.GetMentionsOfUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := userarchive.Archive{
+		Manifest: userarchive.Manifest{
+			ExportedAt: time.Now().UTC(),
+			UserID:     user.ID,
+		},
+		Profile: userarchive.Profile{
+			ID:                  user.ID,
+			CreatedAt:           user.CreatedAt,
+			UpdatedAt:           user.UpdatedAt,
+			Email:               user.Email,
+			EmailVerifiedAt:     nullTimePtr(user.EmailVerifiedAt),
+			IsChirpyRed:         user.IsChirpyRed,
+			TwoFactorEnabledAt:  nullTimePtr(user.TotpEnabledAt),
+			DeletionScheduledAt: nullTimePtr(user.DeletionScheduledAt),
+		},
+		Chirps:       []userarchive.Chirp{},
+		Mentions:     []userarchive.Mention{},
+		Following:    []userarchive.Follow{},
+		Followers:    []userarchive.Follow{},
+		Sessions:     []userarchive.Session{},
+		AccessTokens: []userarchive.AccessToken{},
+		Identities:   []userarchive.Identity{},
+		OAuthGrants:  []userarchive.OAuthGrant{},
+		OAuthClients: []userarchive.OAuthClient{},
+		Imports:      []userarchive.Import{},
+	}
+	mentionsOfPost := map[uuid.UUID][]uuid.UUID{}
+	for _, mention := range mentioned {
+		mentionsOfPost[mention.PostID] = append(mentionsOfPost[mention.PostID], mention.UserID)
+	}
+	for _, post := range posts {
+		archive.Chirps = append(archive.Chirps, userarchive.Chirp{
+			ID:         post.ID,
+			CreatedAt:  post.CreatedAt,
+			UpdatedAt:  post.UpdatedAt,
+			Body:       post.Body,
+			Visibility: post.Visibility,
+			PinnedAt:   nullTimePtr(post.PinnedAt),
+			ExpiresAt:  nullTimePtr(post.ExpiresAt),
+			Mentions:   mentionsOfPost[post.ID],
+		})
+	}
+	for _, mention := range mentions {
+		archive.Mentions = append(archive.Mentions, userarchive.Mention{
+			ChirpID:  mention.PostID,
+			AuthorID: mention.AuthorID,
+		})
+	}
+	for _, follow := range following {
+		archive.Following = append(archive.Following, userarchive.Follow{
+			UserID:    follow.FolloweeID,
+			CreatedAt: follow.CreatedAt,
+		})
+	}
+	for _, follow := range followers {
+		archive.Followers = append(archive.Followers, userarchive.Follow{
+			UserID:    follow.FollowerID,
+			CreatedAt: follow.CreatedAt,
+		})
+	}
+	for _, session := range sessions {
+		archive.Sessions = append(archive.Sessions, userarchive.Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			RevokedAt:  nullTimePtr(session.RevokedAt),
+			IPAddress:  session.IpAddress,
+			UserAgent:  session.UserAgent,
+		})
+	}
+	for _, pat := range pats {
+		archive.AccessTokens = append(archive.AccessTokens, userarchive.AccessToken{
+			ID:         pat.ID,
+			CreatedAt:  pat.CreatedAt,
+			Name:       pat.Name,
+			Scopes:     pat.Scopes,
+			ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+			LastUsedAt: nullTimePtr(pat.LastUsedAt),
+			RevokedAt:  nullTimePtr(pat.RevokedAt),
+		})
+	}
+	for _, identity := range identities {
+		archive.Identities = append(archive.Identities, userarchive.Identity{
+			ID:          identity.ID,
+			CreatedAt:   identity.CreatedAt,
+			Provider:    identity.Provider,
+			Issuer:      identity.Issuer,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: nullTimePtr(identity.LastLoginAt),
+		})
+	}
+	for _, grant := range grants {
+		archive.OAuthGrants = append(archive.OAuthGrants, userarchive.OAuthGrant{
+			ID:         grant.ID,
+			CreatedAt:  grant.CreatedAt,
+			ClientID:   grant.ClientID,
+			Scopes:     grant.Scopes,
+			LastUsedAt: nullTimePtr(grant.LastUsedAt),
+			RevokedAt:  nullTimePtr(grant.RevokedAt),
+		})
+	}
+	for _, client := range clients {
+		archive.OAuthClients = append(archive.OAuthClients, userarchive.OAuthClient{
+			ID:           client.ID,
+			CreatedAt:    client.CreatedAt,
+			Name:         client.Name,
+			RedirectURIs: client.RedirectUris,
+			Scopes:       client.Scopes,
+			Public:       !client.SecretHash.Valid,
+		})
+	}
+	for _, job := range imports {
+		itemErrors, err := cfg.db.GetImportItemErrors(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		imported := userarchive.Import{
+			ID:          job.ID,
+			CreatedAt:   job.CreatedAt,
+			Status:      job.Status,
+			Error:       job.Error.String,
+			Total:       int(job.Total),
+			Imported:    int(job.Imported),
+			Skipped:     int(job.Skipped),
+			Failed:      int(job.Failed),
+			CompletedAt: nullTimePtr(job.CompletedAt),
+		}
+		for _, itemError := range itemErrors {
+			imported.Errors = append(imported.Errors, userarchive.ImportError{
+				Item:    int(itemError.Item),
+				Message: itemError.Message,
+			})
+		}
+		archive.Imports = append(archive.Imports, imported)
+	}
+
+	var buf bytes.Buffer
+	err = userarchive.Write(&buf, archive)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runExportJob builds the archive of a claimed job and stores it, or
+// records why it couldn't.
+func (cfg *apiConfig) runExportJob(ctx context.Context, job database.ExportJob) error {
+	archive, err := cfg.buildExport(ctx, job.UserID)
+	if err != nil {
+		return cfg.db.FailExportJob(ctx, database.FailExportJobParams{
+			ID:    job.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+	}
+
+	err = cfg.db.CreateExportArchive(ctx, database.CreateExportArchiveParams{
+		ExportJobID: job.ID,
+		Data:        archive,
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.db.CompleteExportJob(ctx, database.CompleteExportJobParams{
+		ID:               job.ID,
+		ExpiresInSeconds: exportLifetime.Seconds(),
+	})
+}
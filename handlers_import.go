@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/chirptext"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/entitlements"
+	"github.com/AbdKaan/chirpy/internal/userarchive"
+	"github.com/google/uuid"
+)
+
+// maxImportSize is the largest upload accepted for an import.
+const maxImportSize = 10 << 20
+
+// handlerImport takes a Chirpy export archive or JSON Lines of chirps as
+// the request body and queues it for import.
+func (cfg *apiConfig) handlerImport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get user", err)
+		return
+	}
+	if !cfg.unverified.Allows(user, entitlements.ActionChirp) {
+		respondWithError(w, http.StatusForbidden, "Verify your email before chirping", nil)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+		return
+	}
+
+	// reject what can't be imported at all now, single bad chirps are
+	// reported once the job has run
+	_, err = userarchive.ReadChirps(data)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Upload must be a Chirpy export or JSON Lines of chirps", err)
+		return
+	}
+
+	job, err := cfg.db.CreateImportJob(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start import", err)
+		return
+	}
+
+	err = cfg.db.CreateImportUpload(r.Context(), database.CreateImportUploadParams{
+		ImportJobID: job.ID,
+		Data:        data,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store upload", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, databaseImportJobToImportJob(job))
+}
+
+func (cfg *apiConfig) handlerGetImport(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	importID, err := uuid.Parse(r.PathValue("importID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse import ID", err)
+		return
+	}
+
+	job, err := cfg.db.GetImportJob(r.Context(), database.GetImportJobParams{
+		ID:     importID,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't get import", err)
+		return
+	}
+
+	itemErrors, err := cfg.db.GetImportItemErrors(r.Context(), job.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get import errors", err)
+		return
+	}
+
+	response := databaseImportJobToImportJob(job)
+	for _, itemError := range itemErrors {
+		response.Errors = append(response.Errors, ImportItemError{
+			Item:    int(itemError.Item),
+			Message: itemError.Message,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// runImportJob imports the chirps of a claimed job. Chirps that can't be
+// imported are recorded and skipped, the job only fails when the upload
+// can't be read or the database gives out, and running it again is safe.
+func (cfg *apiConfig) runImportJob(ctx context.Context, job database.ImportJob) error {
+	fail := func(reason error) error {
+		return cfg.db.FailImportJob(ctx, database.FailImportJobParams{
+			ID:    job.ID,
+			Error: sql.NullString{String: reason.Error(), Valid: true},
+		})
+	}
+
+	upload, err := cfg.db.GetImportUpload(ctx, job.ID)
+	if err != nil {
+		return fail(err)
+	}
+	items, err := userarchive.ReadChirps(upload)
+	if err != nil {
+		return fail(err)
+	}
+	user, err := cfg.db.GetUser(ctx, job.UserID)
+	if err != nil {
+		return fail(err)
+	}
+	limits := cfg.tiers.For(user)
+
+	importedToday, err := cfg.db.CountChirpsImportedByUserInLastDay(ctx, user.ID)
+	if err != nil {
+		return fail(err)
+	}
+	allowance := int32(max(int64(limits.ImportedChirpsPerDay)-importedToday, 0))
+
+	params := database.CompleteImportJobParams{
+		ID:    job.ID,
+		Total: int32(len(items)),
+	}
+	for i, item := range items {
+		// the rest fail without an error each, importing the same upload
+		// again later picks up where this one stopped
+		if params.Imported >= allowance {
+			params.Failed += int32(len(items) - i)
+			err = cfg.db.CreateImportItemError(ctx, database.CreateImportItemErrorParams{
+				ImportJobID: job.ID,
+				Item:        int32(item.Number),
+				Message:     fmt.Sprintf("Only %d chirps can be imported a day, import the rest later", limits.ImportedChirpsPerDay),
+			})
+			if err != nil {
+				return fail(err)
+			}
+			break
+		}
+
+		imported, reason, err := cfg.importChirp(ctx, user.ID, limits.MaxChirpLength, item)
+		if err != nil {
+			return fail(err)
+		}
+
+		switch {
+		case reason != "":
+			params.Failed++
+			err = cfg.db.CreateImportItemError(ctx, database.CreateImportItemErrorParams{
+				ImportJobID: job.ID,
+				Item:        int32(item.Number),
+				Message:     reason,
+			})
+			if err != nil {
+				return fail(err)
+			}
+		case imported:
+			params.Imported++
+		default:
+			params.Skipped++
+		}
+	}
+
+	err = cfg.db.CompleteImportJob(ctx, params)
+	if err != nil {
+		return err
+	}
+	return cfg.db.DeleteImportUpload(ctx, job.ID)
+}
+
+// importChirp imports a single item and reports whether it was new. A
+// non-empty reason is why the item was rejected.
+func (cfg *apiConfig) importChirp(ctx context.Context, userID uuid.UUID, maxLength int, item userarchive.Item) (imported bool, reason string, err error) {
+	if item.Err != nil {
+		return false, item.Err.Error(), nil
+	}
+	chirp := item.Chirp.UTC()
+
+	body, err := chirptext.Validate(chirp.Body, maxLength)
+	if err != nil {
+		return false, chirpTextErrorMessage(err), nil
+	}
+
+	if chirp.Visibility == "" {
+		chirp.Visibility = visibilityPublic
+	}
+	switch chirp.Visibility {
+	case visibilityPublic, visibilityFollowers, visibilityMentioned:
+	default:
+		return false, "Visibility must be public, followers or mentioned", nil
+	}
+
+	if chirp.CreatedAt.After(time.Now()) {
+		return false, "Chirp was created in the future", nil
+	}
+	if chirp.UpdatedAt.Before(chirp.CreatedAt) {
+		chirp.UpdatedAt = chirp.CreatedAt
+	}
+	expiresAt := sql.NullTime{}
+	if chirp.ExpiresAt != nil {
+		if chirp.ExpiresAt.Before(time.Now()) {
+			return false, "Chirp has already expired", nil
+		}
+		expiresAt = sql.NullTime{Time: *chirp.ExpiresAt, Valid: true}
+	}
+
+	// an archive imported back into the account it came from has nothing
+	// new in it
+	if chirp.ID != uuid.Nil {
+		existing, err := cfg.db.GetPost(ctx, chirp.ID)
+		if err == nil && existing.UserID == userID {
+			return false, "", nil
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return false, "", err
+		}
+	}
+
+	err = cfg.inTx(ctx, func(q *database.Queries) error {
+		post, err := q.ImportPost(ctx, database.ImportPostParams{
+			CreatedAt:  chirp.CreatedAt,
+			UpdatedAt:  chirp.UpdatedAt,
+			Body:       body,
+			UserID:     userID,
+			Visibility: chirp.Visibility,
+			ExpiresAt:  expiresAt,
+			ImportKey:  sql.NullString{String: chirp.Key(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return saveMentions(ctx, q, post)
+	})
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, "", nil
+}
+
+func databaseImportJobToImportJob(job database.ImportJob) ImportJob {
+	return ImportJob{
+		ID:          job.ID,
+		CreatedAt:   job.CreatedAt,
+		Status:      job.Status,
+		Error:       job.Error.String,
+		Total:       int(job.Total),
+		Imported:    int(job.Imported),
+		Skipped:     int(job.Skipped),
+		Failed:      int(job.Failed),
+		CompletedAt: nullTimePtr(job.CompletedAt),
+	}
+}
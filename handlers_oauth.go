@@ -0,0 +1,680 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// oauthAccessTokenLifetime is how long access tokens issued to OAuth
+// clients last, clients use their refresh token for new ones.
+const oauthAccessTokenLifetime = time.Hour
+
+// scopeDescriptions tell the user on the consent screen what a client is
+// asking for.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read chirps, including the ones only you can see",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Change your profile",
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>Authorize {{.Client}} - Chirpy</title>
+  </head>
+  <body>
+    {{if .Fatal}}
+    <h1>Can't authorize this app</h1>
+    <p>{{.Message}}</p>
+    {{else}}
+    <h1>{{.Client}} wants to access your Chirpy account</h1>
+    <p>It will be able to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{if .Message}}<p><strong>{{.Message}}</strong></p>{{end}}
+    <form method="post" action="/oauth/authorize">
+      <input type="hidden" name="response_type" value="code">
+      <input type="hidden" name="client_id" value="{{.ClientID}}">
+      <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+      <input type="hidden" name="scope" value="{{.Scope}}">
+      <input type="hidden" name="state" value="{{.State}}">
+      <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+      <input type="hidden" name="code_challenge_method" value="S256">
+      <p><label>Email <input type="email" name="email" value="{{.Email}}" required></label></p>
+      <p><label>Password <input type="password" name="password"></label></p>
+      <p><label>Two-factor code, if you have it on <input type="text" name="code" inputmode="numeric" autocomplete="one-time-code"></label></p>
+      <button type="submit" name="decision" value="allow">Allow</button>
+      <button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+    </form>
+    {{end}}
+  </body>
+</html>
+`))
+
+// authorizeRequest is a client's request for the user's consent.
+type authorizeRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// handlerOAuthAuthorize shows the consent screen. The user signs in on it,
+// Chirpy has no browser sessions to remember them by.
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, ok := cfg.parseAuthorizeRequest(w, r, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	renderConsent(w, http.StatusOK, req, "", "")
+}
+
+// handlerOAuthConsent handles the consent form. When the user allows the
+// client, they are sent back to it with an authorization code.
+func (cfg *apiConfig) handlerOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		renderConsentError(w, http.StatusBadRequest, "The form couldn't be read.")
+		return
+	}
+
+	req, ok := cfg.parseAuthorizeRequest(w, r, r.PostForm)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithOAuthError(w, r, req, "access_denied", "The user denied access")
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	user, status, message := cfg.checkConsentLogin(r, email, r.PostForm.Get("password"), r.PostForm.Get("code"))
+	if message != "" {
+		renderConsent(w, status, req, email, message)
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		renderConsentError(w, http.StatusInternalServerError, "Something went wrong, try again.")
+		return
+	}
+
+	err = cfg.db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashRefreshToken(code, cfg.refreshTokenKey),
+		ClientID:      req.client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.redirectURI,
+		Scopes:        req.scopes,
+		CodeChallenge: req.codeChallenge,
+	})
+	if err != nil {
+		log.Printf("Couldn't store authorization code: %s", err)
+		renderConsentError(w, http.StatusInternalServerError, "Something went wrong, try again.")
+		return
+	}
+
+	redirectToClient(w, r, req, url.Values{"code": {code}})
+}
+
+// parseAuthorizeRequest checks an authorization request. Until the client
+// and redirect URI are known to be right, errors are shown to the user,
+// after that they go back to the client. ok is false when a response was
+// sent.
+func (cfg *apiConfig) parseAuthorizeRequest(w http.ResponseWriter, r *http.Request, values url.Values) (authorizeRequest, bool) {
+	clientID, err := uuid.Parse(values.Get("client_id"))
+	if err != nil {
+		renderConsentError(w, http.StatusBadRequest, "The app didn't say who it is.")
+		return authorizeRequest{}, false
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		renderConsentError(w, http.StatusBadRequest, "The app isn't registered with Chirpy.")
+		return authorizeRequest{}, false
+	}
+
+	redirectURI := values.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		renderConsentError(w, http.StatusBadRequest, "The app asked to send you somewhere it isn't registered for.")
+		return authorizeRequest{}, false
+	}
+
+	req := authorizeRequest{
+		client:        client,
+		redirectURI:   redirectURI,
+		state:         values.Get("state"),
+		codeChallenge: values.Get("code_challenge"),
+	}
+
+	if values.Get("response_type") != "code" {
+		redirectWithOAuthError(w, r, req, "unsupported_response_type", "Only the code response type is supported")
+		return authorizeRequest{}, false
+	}
+	if req.codeChallenge == "" || values.Get("code_challenge_method") != "S256" {
+		redirectWithOAuthError(w, r, req, "invalid_request", "PKCE with the S256 method is required")
+		return authorizeRequest{}, false
+	}
+
+	// without a scope the client gets everything it's registered for
+	req.scopes = strings.Fields(values.Get("scope"))
+	if len(req.scopes) == 0 {
+		req.scopes = client.Scopes
+	}
+	for _, scope := range req.scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			redirectWithOAuthError(w, r, req, "invalid_scope", "The client isn't registered for "+scope)
+			return authorizeRequest{}, false
+		}
+	}
+
+	return req, true
+}
+
+// checkConsentLogin signs the user in on the consent screen, with the same
+// throttling and second factor as logging in through the API. A non-empty
+// message is why they couldn't be signed in.
+func (cfg *apiConfig) checkConsentLogin(r *http.Request, email, password, code string) (database.User, int, string) {
+	ip := clientIP(r)
+	wait, err := cfg.loginWait(r.Context(), email, ip)
+	if err != nil {
+		log.Printf("Couldn't check failed logins: %s", err)
+		return database.User{}, http.StatusInternalServerError, "Something went wrong, try again."
+	}
+	if wait > 0 {
+		return database.User{}, http.StatusTooManyRequests, "Too many failed logins, try again later."
+	}
+
+	user, err := cfg.db.GetUserWithEmail(r.Context(), email)
+	if err != nil {
+		cfg.passwords.CheckDummy(password)
+		if err := cfg.recordLoginFailure(r.Context(), email, ip, nil); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
+		return database.User{}, http.StatusUnauthorized, "Incorrect email or password."
+	}
+
+	_, err = cfg.passwords.Check(password, user.HashedPassword)
+	if err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), email, ip, &user); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
+		return database.User{}, http.StatusUnauthorized, "Incorrect email or password."
+	}
+
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.useTOTPCode(r.Context(), user, code)
+		if err != nil {
+			log.Printf("Couldn't check two-factor code: %s", err)
+			return database.User{}, http.StatusInternalServerError, "Something went wrong, try again."
+		}
+		if !ok {
+			if err := cfg.recordLoginFailure(r.Context(), email, ip, &user); err != nil {
+				log.Printf("Couldn't record failed login: %s", err)
+			}
+			return database.User{}, http.StatusUnauthorized, "Incorrect or already used two-factor code."
+		}
+	}
+
+	err = cfg.clearLoginFailures(r.Context(), email)
+	if err != nil {
+		log.Printf("Couldn't clear failed logins: %s", err)
+		return database.User{}, http.StatusInternalServerError, "Something went wrong, try again."
+	}
+
+	return user, http.StatusOK, ""
+}
+
+func renderConsent(w http.ResponseWriter, status int, req authorizeRequest, email, message string) {
+	scopes := []string{}
+	for _, scope := range req.scopes {
+		scopes = append(scopes, scopeDescriptions[scope])
+	}
+
+	writeConsentPage(w, status, map[string]any{
+		"Client":        req.client.Name,
+		"ClientID":      req.client.ID,
+		"RedirectURI":   req.redirectURI,
+		"Scope":         strings.Join(req.scopes, " "),
+		"Scopes":        scopes,
+		"State":         req.state,
+		"CodeChallenge": req.codeChallenge,
+		"Email":         email,
+		"Message":       message,
+	})
+}
+
+func renderConsentError(w http.ResponseWriter, status int, message string) {
+	writeConsentPage(w, status, map[string]any{
+		"Client":  "app",
+		"Fatal":   true,
+		"Message": message,
+	})
+}
+
+func writeConsentPage(w http.ResponseWriter, status int, data map[string]any) {
+	// the page takes a password, keep it out of frames and caches
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+
+	err := consentPage.Execute(w, data)
+	if err != nil {
+		log.Printf("Couldn't render consent page: %s", err)
+	}
+}
+
+// redirectToClient sends the user back to the client with params and the
+// state it started with.
+func redirectToClient(w http.ResponseWriter, r *http.Request, req authorizeRequest, params url.Values) {
+	u, _ := url.Parse(req.redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.state != "" {
+		query.Set("state", req.state)
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func redirectWithOAuthError(w http.ResponseWriter, r *http.Request, req authorizeRequest, code, description string) {
+	redirectToClient(w, r, req, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+// handlerOAuthToken exchanges authorization codes and refresh tokens for
+// access tokens. Every exchange hands out a new refresh token and the old
+// one stops working.
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.exchangeOAuthRefreshToken(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code and refresh_token are supported")
+	}
+}
+
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	codeHash := auth.HashRefreshToken(r.PostForm.Get("code"), cfg.refreshTokenKey)
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't create refresh token")
+		return
+	}
+
+	// the code is used up even when the rest of the request is wrong. The
+	// transaction keeps a replay from getting in before the grant is
+	// recorded on the code.
+	var grant database.OauthGrant
+	invalid := ""
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		code, err := q.UseOAuthAuthorizationCode(r.Context(), codeHash)
+		if err == sql.ErrNoRows {
+			invalid = "Authorization code is invalid, expired or was already used"
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case code.ClientID != client.ID:
+			invalid = "Authorization code is invalid, expired or was already used"
+		case r.PostForm.Get("redirect_uri") != code.RedirectUri:
+			invalid = "redirect_uri doesn't match the authorization request"
+		case !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge):
+			invalid = "code_verifier doesn't match the code challenge"
+		}
+		if invalid != "" {
+			return nil
+		}
+
+		grant, err = q.CreateOAuthGrant(r.Context(), database.CreateOAuthGrantParams{
+			ClientID:         client.ID,
+			UserID:           code.UserID,
+			Scopes:           code.Scopes,
+			RefreshTokenHash: auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.SetOAuthAuthorizationCodeGrant(r.Context(), database.SetOAuthAuthorizationCodeGrantParams{
+			CodeHash: codeHash,
+			GrantID:  uuid.NullUUID{UUID: grant.ID, Valid: true},
+		})
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't store grant")
+		return
+	}
+	if invalid != "" {
+		cfg.revokeReplayedAuthorizationCode(r.Context(), codeHash)
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", invalid)
+		return
+	}
+
+	cfg.respondWithOAuthTokens(w, r, grant, refreshToken)
+}
+
+// revokeReplayedAuthorizationCode revokes the grant a code was exchanged
+// for when the code comes back again, as someone other than the client may
+// have it (RFC 6749 section 4.1.2).
+func (cfg *apiConfig) revokeReplayedAuthorizationCode(ctx context.Context, codeHash string) {
+	code, err := cfg.db.GetUsedOAuthAuthorizationCode(ctx, codeHash)
+	if err != nil || !code.GrantID.Valid {
+		return
+	}
+
+	err = cfg.db.RevokeOAuthGrant(ctx, code.GrantID.UUID)
+	if err != nil {
+		log.Printf("Couldn't revoke grant %s of a replayed authorization code: %s", code.GrantID.UUID, err)
+		return
+	}
+	log.Printf("Revoked grant %s, its authorization code was used again", code.GrantID.UUID)
+}
+
+func (cfg *apiConfig) exchangeOAuthRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	oldHash := auth.HashRefreshToken(r.PostForm.Get("refresh_token"), cfg.refreshTokenKey)
+	grant, err := cfg.db.GetActiveOAuthGrantWithRefreshToken(r.Context(), oldHash)
+	if err == sql.ErrNoRows {
+		cfg.revokeReplayedRefreshToken(r.Context(), oldHash)
+	}
+	if err != nil || grant.ClientID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token is invalid or was revoked")
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't create refresh token")
+		return
+	}
+
+	// a concurrent refresh with the same token rotates it first, this one
+	// then finds nothing to rotate. The old token is kept so a replay of it
+	// can be caught.
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		grant, err = q.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{
+			NewHash: auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+			OldHash: oldHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.CreateOAuthUsedRefreshToken(r.Context(), database.CreateOAuthUsedRefreshTokenParams{
+			TokenHash: oldHash,
+			GrantID:   grant.ID,
+		})
+	})
+	if err == sql.ErrNoRows {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token is invalid or was revoked")
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't rotate refresh token")
+		return
+	}
+
+	cfg.respondWithOAuthTokens(w, r, grant, refreshToken)
+}
+
+// revokeReplayedRefreshToken revokes the grant of a refresh token that was
+// already rotated out. Either the client or whoever replayed it has a
+// stolen token, so neither gets to keep going.
+func (cfg *apiConfig) revokeReplayedRefreshToken(ctx context.Context, tokenHash string) {
+	used, err := cfg.db.GetOAuthUsedRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return
+	}
+
+	err = cfg.db.RevokeOAuthGrant(ctx, used.GrantID)
+	if err != nil {
+		log.Printf("Couldn't revoke grant %s of a replayed refresh token: %s", used.GrantID, err)
+		return
+	}
+	log.Printf("Revoked grant %s, its old refresh token was used again", used.GrantID)
+}
+
+func (cfg *apiConfig) respondWithOAuthTokens(w http.ResponseWriter, r *http.Request, grant database.OauthGrant, refreshToken string) {
+	user, err := cfg.db.GetUser(r.Context(), grant.UserID)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't get user")
+		return
+	}
+
+	accessToken, err := auth.MakeOAuthToken(user.ID, user.TokenVersion, grant.ClientID, grant.ID, grant.Scopes, cfg.jwtKeys, oauthAccessTokenLifetime)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't create or sign token")
+		return
+	}
+
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(grant.Scopes, " "),
+	})
+}
+
+// handlerOAuthIntrospect tells a client whether one of its tokens is still
+// active and what it was granted. Tokens of other clients are reported as
+// inactive.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	type response struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+	}
+
+	grant, accessToken, err := cfg.findOAuthGrant(r.Context(), r.PostForm.Get("token"))
+	if err != nil || grant.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, response{Active: false})
+		return
+	}
+
+	introspection := response{
+		Active:    true,
+		Scope:     strings.Join(grant.Scopes, " "),
+		ClientID:  grant.ClientID.String(),
+		Subject:   grant.UserID.String(),
+		TokenType: "refresh_token",
+	}
+	if accessToken != nil {
+		introspection.Scope = strings.Join(accessToken.Scopes, " ")
+		introspection.TokenType = "Bearer"
+		introspection.IssuedAt = accessToken.IssuedAt.Unix()
+		introspection.ExpiresAt = accessToken.ExpiresAt.Unix()
+	}
+
+	respondWithJSON(w, http.StatusOK, introspection)
+}
+
+// handlerOAuthRevoke revokes the grant an access or refresh token belongs
+// to, which ends the client's access through any of its tokens. Unknown
+// tokens aren't an error, they are as revoked as they'll ever be.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	grant, _, err := cfg.findOAuthGrant(r.Context(), r.PostForm.Get("token"))
+	if err == nil && grant.ClientID == client.ID {
+		err = cfg.db.RevokeOAuthGrant(r.Context(), grant.ID)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "Couldn't revoke token, try again")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// findOAuthGrant finds the active grant of an access or refresh token.
+// For access tokens, the validated token is returned too.
+func (cfg *apiConfig) findOAuthGrant(ctx context.Context, token string) (database.OauthGrant, *auth.AccessToken, error) {
+	accessToken, err := auth.ValidateAccessToken(ctx, token, cfg.jwtKeys, cfg.tokenVersions)
+	if err == nil && accessToken.IsOAuth() {
+		grant, err := cfg.db.GetActiveOAuthGrant(ctx, accessToken.GrantID)
+		return grant, &accessToken, err
+	}
+
+	grant, err := cfg.db.GetActiveOAuthGrantWithRefreshToken(ctx, auth.HashRefreshToken(token, cfg.refreshTokenKey))
+	return grant, nil, err
+}
+
+// authenticateOAuthClient parses the form of a token, introspection or
+// revocation request and checks the client's credentials. They come in
+// Basic auth or in the form, public clients only send their ID.
+func (cfg *apiConfig) authenticateOAuthClient(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Couldn't parse form")
+		return database.OauthClient{}, false
+	}
+
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	fail := func() (database.OauthClient, bool) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return database.OauthClient{}, false
+	}
+
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return fail()
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), id)
+	if err == sql.ErrNoRows {
+		return fail()
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "Couldn't get client")
+		return database.OauthClient{}, false
+	}
+
+	if !client.SecretHash.Valid {
+		if secret != "" {
+			return fail()
+		}
+		return client, true
+	}
+	hash := auth.HashRefreshToken(secret, cfg.refreshTokenKey)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+		return fail()
+	}
+	return client, true
+}
+
+// respondWithOAuthError responds with an error in the format RFC 6749
+// prescribes, OAuth client libraries expect it.
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthError, description string) {
+	type response struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, response{
+		Error:            oauthError,
+		ErrorDescription: description,
+	})
+}
+
+// handlerOAuthMetadata is the RFC 8414 metadata client libraries configure
+// themselves from. The issuer is the iss claim access tokens carry, so
+// clients checking it against the metadata accept them.
+func (cfg *apiConfig) handlerOAuthMetadata(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Issuer                            string   `json:"issuer"`
+		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+		TokenEndpoint                     string   `json:"token_endpoint"`
+		IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		JWKSURI                           string   `json:"jwks_uri"`
+		ScopesSupported                   []string `json:"scopes_supported"`
+		ResponseTypesSupported            []string `json:"response_types_supported"`
+		GrantTypesSupported               []string `json:"grant_types_supported"`
+		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Issuer:                            auth.Issuer,
+		AuthorizationEndpoint:             cfg.baseURL + "/oauth/authorize",
+		TokenEndpoint:                     cfg.baseURL + "/oauth/token",
+		IntrospectionEndpoint:             cfg.baseURL + "/oauth/introspect",
+		RevocationEndpoint:                cfg.baseURL + "/oauth/revoke",
+		JWKSURI:                           cfg.baseURL + "/.well-known/jwks.json",
+		ScopesSupported:                   auth.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
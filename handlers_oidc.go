@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/oidc"
+	"github.com/google/uuid"
+)
+
+// oidcStateCookie ties a sign-in to the browser that started it, so nobody
+// can get someone else signed in by sending them a callback link.
+const oidcStateCookie = "chirpy_oidc_state"
+
+// handlerOIDCLogin sends the user to the provider to sign in. They come
+// back to handlerOIDCCallback.
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+		return
+	}
+
+	state, authURL, err := cfg.startOIDCLogin(r.Context(), provider, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't start sign in with "+provider.Name, err)
+		return
+	}
+
+	cfg.setOIDCStateCookie(w, state)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) setOIDCStateCookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.baseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// handlerLinkIdentity starts linking an identity at the provider to the
+// signed in user. The client sends the user to the returned URL, which
+// goes through handlerOIDCLink so the browser gets the state cookie too,
+// and confirms the link token the callback answers with.
+func (cfg *apiConfig) handlerLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	provider, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+		return
+	}
+
+	state, _, err := cfg.startOIDCLogin(r.Context(), provider, uuid.NullUUID{UUID: userId, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't start sign in with "+provider.Name, err)
+		return
+	}
+
+	type response struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		AuthorizationURL: cfg.baseURL + "/api/oidc/" + provider.Name + "/link?state=" + url.QueryEscape(state),
+	})
+}
+
+// handlerOIDCLink sets the state cookie of a link started by
+// handlerLinkIdentity in the browser that opens it and sends the user on
+// to the provider. The callback checks the cookie like for any sign-in.
+func (cfg *apiConfig) handlerOIDCLink(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	loginState, err := cfg.db.GetOIDCLoginState(r.Context(), auth.HashRefreshToken(state, cfg.refreshTokenKey))
+	if err != nil || loginState.Provider != provider.Name || !loginState.UserID.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Link expired or was already used, start again", err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, loginState.Nonce, loginState.CodeVerifier, cfg.oidcRedirectURI(provider))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't start sign in with "+provider.Name, err)
+		return
+	}
+
+	cfg.setOIDCStateCookie(w, state)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// startOIDCLogin stores what's needed to finish the sign-in and returns
+// its state and the URL to send the user to.
+func (cfg *apiConfig) startOIDCLogin(ctx context.Context, provider *oidc.Provider, linkTo uuid.NullUUID) (string, string, error) {
+	state, err := oidc.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier, cfg.oidcRedirectURI(provider))
+	if err != nil {
+		return "", "", err
+	}
+
+	err = cfg.db.CreateOIDCLoginState(ctx, database.CreateOIDCLoginStateParams{
+		StateHash:    auth.HashRefreshToken(state, cfg.refreshTokenKey),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       linkTo,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return state, authURL, nil
+}
+
+func (cfg *apiConfig) oidcRedirectURI(provider *oidc.Provider) string {
+	return cfg.baseURL + "/api/oidc/" + provider.Name + "/callback"
+}
+
+// handlerOIDCCallback finishes a sign-in at the provider. Known identities
+// log in as their user, new ones get linked to the user with the same
+// verified email or get a new user. When the sign-in was started to link
+// an identity, a link token is returned for handlerConfirmIdentityLink.
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		respondWithError(w, http.StatusUnauthorized, "Sign in with "+provider.Name+" failed: "+query.Get("error"), nil)
+		return
+	}
+
+	state := query.Get("state")
+	loginState, err := cfg.db.UseOIDCLoginState(r.Context(), auth.HashRefreshToken(state, cfg.refreshTokenKey))
+	if err != nil || loginState.Provider != provider.Name {
+		respondWithError(w, http.StatusUnauthorized, "Sign in expired or was already used, start again", err)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		respondWithError(w, http.StatusUnauthorized, "Sign in was started in another browser", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/oidc/", MaxAge: -1})
+
+	idToken, err := provider.Exchange(r.Context(), query.Get("code"), loginState.CodeVerifier, cfg.oidcRedirectURI(provider))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't finish sign in with "+provider.Name, err)
+		return
+	}
+	identity, err := provider.VerifyIDToken(r.Context(), idToken, loginState.Nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Identity provider sent an invalid ID token", err)
+		return
+	}
+
+	existing, err := cfg.db.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	})
+	found := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up identity", err)
+		return
+	}
+
+	// the identity is only linked once the user who started the link
+	// confirms it, whoever signed in at the provider may not be them
+	if loginState.UserID.Valid {
+		if found && existing.UserID != loginState.UserID.UUID {
+			respondWithError(w, http.StatusConflict, "This identity is linked to another user", nil)
+			return
+		}
+
+		linkToken, err := auth.MakeLinkToken(auth.PendingLink{
+			UserID:   loginState.UserID.UUID,
+			Provider: provider.Name,
+			Issuer:   identity.Issuer,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}, cfg.jwtKeys, 10*time.Minute)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create or sign token", err)
+			return
+		}
+
+		type response struct {
+			LinkToken string `json:"link_token"`
+		}
+
+		respondWithJSON(w, http.StatusOK, response{
+			LinkToken: linkToken,
+		})
+		return
+	}
+
+	if found {
+		err = cfg.db.TouchUserIdentity(r.Context(), database.TouchUserIdentityParams{
+			ID:    existing.ID,
+			Email: identity.Email,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update identity", err)
+			return
+		}
+
+		user, err := cfg.db.GetUser(r.Context(), existing.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+
+		cfg.respondWithFirstFactor(w, r, user)
+		return
+	}
+
+	user, ok := cfg.userForIdentity(w, r, identity)
+	if !ok {
+		return
+	}
+
+	_, err = cfg.db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider.Name,
+		Issuer:   identity.Issuer,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't link identity", err)
+		return
+	}
+
+	cfg.respondWithFirstFactor(w, r, user)
+}
+
+// userForIdentity finds the user a new identity belongs to by its email,
+// or creates one. Matching by email is only safe when both sides have
+// verified it, otherwise the user has to link the identity themselves.
+func (cfg *apiConfig) userForIdentity(w http.ResponseWriter, r *http.Request, identity oidc.Identity) (database.User, bool) {
+	if identity.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "Identity provider didn't share an email", nil)
+		return database.User{}, false
+	}
+	if err := validateEmail(identity.Email); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Identity provider shared an invalid email", err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserWithEmail(r.Context(), identity.Email)
+	if err == nil {
+		if !identity.EmailVerified || !user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "An account with this email already exists, log in and link the identity from there", nil)
+			return database.User{}, false
+		}
+		return user, true
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return database.User{}, false
+	}
+
+	// the user signs in through the provider, the password is only there
+	// until they reset it
+	password, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create password", err)
+		return database.User{}, false
+	}
+	hashedPw, err := cfg.passwords.Hash(password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		return database.User{}, false
+	}
+
+	user, err = cfg.db.CreateUser(r.Context(), database.CreateUserParams{
+		Email:          identity.Email,
+		HashedPassword: hashedPw,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
+		return database.User{}, false
+	}
+
+	if identity.EmailVerified {
+		user, err = cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+			ID:    user.ID,
+			Email: user.Email,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+			return database.User{}, false
+		}
+	} else if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+// handlerConfirmIdentityLink links the identity of a link token from
+// handlerOIDCCallback. Only the user who started the link can confirm it.
+func (cfg *apiConfig) handlerConfirmIdentityLink(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		LinkToken string `json:"link_token"`
+	}
+
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	link, err := auth.ValidateLinkToken(params.LinkToken, cfg.jwtKeys, userId)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "Link is invalid, expired or was started by another user", err)
+		return
+	}
+
+	existing, err := cfg.db.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Issuer:  link.Issuer,
+		Subject: link.Subject,
+	})
+	if err == nil {
+		if existing.UserID != userId {
+			respondWithError(w, http.StatusConflict, "This identity is linked to another user", nil)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, databaseIdentityToIdentity(existing))
+		return
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up identity", err)
+		return
+	}
+
+	linked, err := cfg.db.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		UserID:   userId,
+		Provider: link.Provider,
+		Issuer:   link.Issuer,
+		Subject:  link.Subject,
+		Email:    link.Email,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "This identity is linked to another user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't link identity", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, databaseIdentityToIdentity(linked))
+}
+
+func (cfg *apiConfig) handlerGetIdentities(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	identities, err := cfg.db.GetUserIdentitiesOfUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get identities", err)
+		return
+	}
+
+	response := []UserIdentity{}
+	for _, identity := range identities {
+		response = append(response, databaseIdentityToIdentity(identity))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	identityID, err := uuid.Parse(r.PathValue("identityID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse identity ID", err)
+		return
+	}
+
+	_, err = cfg.db.DeleteUserIdentity(r.Context(), database.DeleteUserIdentityParams{
+		ID:     identityID,
+		UserID: userId,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Couldn't find identity", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlink identity", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func databaseIdentityToIdentity(identity database.UserIdentity) UserIdentity {
+	return UserIdentity{
+		ID:          identity.ID,
+		CreatedAt:   identity.CreatedAt,
+		Provider:    identity.Provider,
+		Email:       identity.Email,
+		LastLoginAt: nullTimePtr(identity.LastLoginAt),
+	}
+}
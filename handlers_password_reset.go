@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/mailer"
+)
+
+// Password resets are throttled per email, so nobody's inbox can be
+// flooded with links, and per IP address, so nobody can flood everyone's.
+const (
+	passwordResetWindow    = time.Hour
+	passwordResetsPerEmail = 3
+	passwordResetsPerIP    = 10
+	passwordResetTimeout   = 10 * time.Second
+	passwordResetQueueSize = 100
+)
+
+// passwordResetRequest is a reset waiting for sendPasswordResets.
+type passwordResetRequest struct {
+	email string
+	ip    string
+}
+
+func (cfg *apiConfig) handlerRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	ip := clientIP(r)
+	address, err := cfg.db.GetPasswordResetRequestsOfIP(r.Context(), database.GetPasswordResetRequestsOfIPParams{
+		IpAddress:     ip,
+		WindowSeconds: passwordResetWindow.Seconds(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check password resets", err)
+		return
+	}
+	if address.Requests >= passwordResetsPerIP {
+		wait := passwordResetWindow - secondsToDuration(address.SecondsSinceFirst)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many password resets, try again later", nil)
+		return
+	}
+
+	// the email is only looked up once the request is queued, so neither
+	// the response nor how long it takes tells whether an account has this
+	// email. A full queue drops the reset, the user can ask again.
+	select {
+	case cfg.passwordResets <- passwordResetRequest{email: params.Email, ip: ip}:
+	default:
+		log.Printf("Password reset queue is full, dropping a reset")
+	}
+
+	type response struct {
+		Message string `json:"message"`
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		Message: "If an account has this email, a password reset link is on its way",
+	})
+}
+
+// requestPasswordReset records a reset requested for email from ip and
+// sends it, unless the email had too many resets lately.
+func (cfg *apiConfig) requestPasswordReset(ctx context.Context, email, ip string) error {
+	account, err := cfg.db.GetPasswordResetRequestsOfEmail(ctx, database.GetPasswordResetRequestsOfEmailParams{
+		Email:         strings.ToLower(email),
+		WindowSeconds: passwordResetWindow.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+	if account.Requests >= passwordResetsPerEmail {
+		return nil
+	}
+
+	err = cfg.db.CreatePasswordResetRequest(ctx, database.CreatePasswordResetRequestParams{
+		Email:     strings.ToLower(email),
+		IpAddress: ip,
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.sendPasswordReset(ctx, email)
+}
+
+// sendPasswordReset mails a reset link to the user with email, if there is
+// one.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUserWithEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashRefreshToken(token, cfg.refreshTokenKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	// the page behind the link asks for the new password and posts it with
+	// the token to /api/password-reset/confirm
+	return cfg.sendMail(ctx, user.Email, mailer.TemplatePasswordReset, map[string]any{
+		"Link": cfg.baseURL + "/app/reset-password.html?token=" + url.QueryEscape(token),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// checked first so a rejected password doesn't use up the token
+	err = cfg.passwordPolicy.Validate(params.Password)
+	if err != nil {
+		respondWithPasswordError(w, err)
+		return
+	}
+
+	reset, err := cfg.db.UsePasswordResetToken(r.Context(), auth.HashRefreshToken(params.Token, cfg.refreshTokenKey))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Reset token is invalid or expired", err)
+		return
+	}
+
+	hashedPw, err := cfg.passwords.Hash(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		return
+	}
+
+	_, err = cfg.db.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPw,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update password", err)
+		return
+	}
+
+	err = cfg.db.UsePasswordResetTokensOfUser(r.Context(), reset.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke reset tokens", err)
+		return
+	}
+
+	// whoever knew the old password is signed out everywhere
+	err = cfg.revokeAllSessions(r.Context(), reset.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
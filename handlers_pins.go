@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerPinPost(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	// expired chirps are gone for everyone, their author included
+	post, ok := cfg.getOwnPost(w, r, userId)
+	if !ok {
+		return
+	}
+
+	if post.PinnedAt.Valid {
+		respondWithJSON(w, http.StatusOK, databasePostToPost(post))
+		return
+	}
+
+	// the author stays locked from counting to pinning, so concurrent pins
+	// can't all squeeze in under the limit
+	limitReached := false
+	err = cfg.inTx(r.Context(), func(q *database.Queries) error {
+		user, err := q.GetUserForUpdate(r.Context(), userId)
+		if err != nil {
+			return err
+		}
+
+		pinned, err := q.CountPinnedPostsOfAuthor(r.Context(), userId)
+		if err != nil {
+			return err
+		}
+		if pinned >= int64(cfg.tiers.For(user).MaxPinnedChirps) {
+			limitReached = true
+			return nil
+		}
+
+		post, err = q.PinPost(r.Context(), post.ID)
+		return err
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	if limitReached {
+		respondWithError(w, http.StatusConflict, "Pinned chirp limit reached", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databasePostToPost(post))
+}
+
+func (cfg *apiConfig) handlerUnpinPost(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	post, ok := cfg.getOwnPost(w, r, userId)
+	if !ok {
+		return
+	}
+
+	post, err = cfg.db.UnpinPost(r.Context(), post.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unpin chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databasePostToPost(post))
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
 )
 
 func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request) {
@@ -14,24 +15,81 @@ func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshTokenHeader)
+	tokenHash := auth.HashRefreshToken(refreshTokenHeader, cfg.refreshTokenKey)
+	storedToken, err := cfg.db.GetRefreshToken(r.Context(), tokenHash)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't access refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	// a revoked token being presented again means it was stolen or replayed,
+	// so every token descending from the same login is revoked
+	if storedToken.RevokedAt.Valid {
+		err = cfg.db.RevokeRefreshTokenFamily(r.Context(), storedToken.FamilyID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+			return
+		}
+		_, err = cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+			ID:     storedToken.FamilyID,
+			UserID: storedToken.UserID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Refresh token was reused, please log in again", nil)
+		return
+	}
+
+	_, err = cfg.db.RevokeActiveRefreshToken(r.Context(), tokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't access refresh token", err)
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		return
+	}
+
+	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+		UserID:    storedToken.UserID,
+		FamilyID:  storedToken.FamilyID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		return
+	}
+
+	err = cfg.db.TouchSession(r.Context(), storedToken.FamilyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update session", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), storedToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	accessToken, err := auth.MakeJWT(user.ID, user.TokenVersion, cfg.jwtKeys, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
 		return
 	}
 
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -42,7 +100,16 @@ func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = cfg.db.RevokeRefreshToken(r.Context(), refreshTokenHeader)
+	revokedToken, err := cfg.db.RevokeRefreshToken(r.Context(), auth.HashRefreshToken(refreshTokenHeader, cfg.refreshTokenKey))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	_, err = cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		ID:     revokedToken.FamilyID,
+		UserID: revokedToken.UserID,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
 		return
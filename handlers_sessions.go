@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// startSession records a new login for the user and returns the refresh
+// token that keeps it alive.
+func (cfg *apiConfig) startSession(r *http.Request, userID uuid.UUID) (string, error) {
+	session, err := cfg.db.CreateSession(r.Context(), database.CreateSessionParams{
+		UserID:    userID,
+		IpAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+		UserID:    userID,
+		FamilyID:  session.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// revokeAccessTokens makes every access token issued to the user so far
+// invalid immediately.
+func (cfg *apiConfig) revokeAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.db.IncrementTokenVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	cfg.tokenVersions.Invalidate(userID)
+	return nil
+}
+
+// revokeAllSessions signs the user out everywhere, revoking their sessions,
+// refresh tokens, OAuth grants, personal access tokens and access tokens.
+func (cfg *apiConfig) revokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	err := cfg.db.RevokeSessionsOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.RevokeRefreshTokensOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.RevokeOAuthGrantsOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.RevokePersonalAccessTokensOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return cfg.revokeAccessTokens(ctx, userID)
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	sessions, err := cfg.db.GetActiveSessionsOfUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get sessions", err)
+		return
+	}
+
+	sessionsArr := []Session{}
+	for _, session := range sessions {
+		sessionsArr = append(sessionsArr, Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			IPAddress:  session.IpAddress,
+			UserAgent:  session.UserAgent,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessionsArr)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse session ID", err)
+		return
+	}
+
+	_, err = cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userId,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Couldn't find session", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(r.Context(), sessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	err = cfg.revokeAllSessions(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerCreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expires_in"`
+	}
+
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Token needs a name", nil)
+		return
+	}
+
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Token needs at least one scope", nil)
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			respondWithError(w, http.StatusBadRequest, "Scopes must be chirps:read, chirps:write or profile:write", nil)
+			return
+		}
+	}
+
+	// a token without expires_in is valid until it's revoked
+	expiresIn := sql.NullFloat64{}
+	if params.ExpiresIn != "" {
+		duration, err := time.ParseDuration(params.ExpiresIn)
+		if err != nil || duration <= 0 {
+			respondWithError(w, http.StatusBadRequest, "expires_in must be a positive duration like \"720h\"", err)
+			return
+		}
+		expiresIn = sql.NullFloat64{Float64: duration.Seconds(), Valid: true}
+	}
+
+	token, err := auth.MakePAT()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	pat, err := cfg.db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:           userId,
+		Name:             params.Name,
+		TokenHash:        auth.HashRefreshToken(token, cfg.refreshTokenKey),
+		Scopes:           params.Scopes,
+		ExpiresInSeconds: expiresIn,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save token", err)
+		return
+	}
+
+	type response struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}
+
+	// only the hash is stored, so this is the one time the token is shown
+	respondWithJSON(w, http.StatusCreated, response{
+		PersonalAccessToken: databasePATToPAT(pat),
+		Token:               token,
+	})
+}
+
+func (cfg *apiConfig) handlerGetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	pats, err := cfg.db.GetActivePersonalAccessTokensOfUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get tokens", err)
+		return
+	}
+
+	patsArr := []PersonalAccessToken{}
+	for _, pat := range pats {
+		patsArr = append(patsArr, databasePATToPAT(pat))
+	}
+
+	respondWithJSON(w, http.StatusOK, patsArr)
+}
+
+func (cfg *apiConfig) handlerRevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't parse token ID", err)
+		return
+	}
+
+	_, err = cfg.db.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userId,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Couldn't find token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func databasePATToPAT(pat database.PersonalAccessToken) PersonalAccessToken {
+	return PersonalAccessToken{
+		ID:         pat.ID,
+		CreatedAt:  pat.CreatedAt,
+		Name:       pat.Name,
+		Scopes:     pat.Scopes,
+		ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+		LastUsedAt: nullTimePtr(pat.LastUsedAt),
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/AbdKaan/chirpy/internal/auth"
 	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
@@ -23,7 +25,19 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPw, err := auth.HashPassword(params.Password)
+	err = validateEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Email is invalid", err)
+		return
+	}
+
+	err = cfg.passwordPolicy.Validate(params.Password)
+	if err != nil {
+		respondWithPasswordError(w, err)
+		return
+	}
+
+	hashedPw, err := cfg.passwords.Hash(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
@@ -33,18 +47,22 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		Email:          params.Email,
 		HashedPassword: hashedPw,
 	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already in use", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		// the account is there, the user can ask for another email
+		log.Printf("Couldn't send verification email to %s: %s", user.ID, err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, databaseUserToUser(user))
 }
 
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
@@ -61,38 +79,106 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+	wait, err := cfg.loginWait(r.Context(), params.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check failed logins", err)
+		return
+	}
+	if wait > 0 {
+		respondWithLoginWait(w, wait)
+		return
+	}
+
 	user, err := cfg.db.GetUserWithEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.passwords.CheckDummy(params.Password)
+		if err := cfg.recordLoginFailure(r.Context(), params.Email, ip, nil); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
-	if auth.CheckPasswordHash(params.Password, user.HashedPassword) != nil {
+	needsRehash, err := cfg.passwords.Check(params.Password, user.HashedPassword)
+	if err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), params.Email, ip, &user); err != nil {
+			log.Printf("Couldn't record failed login: %s", err)
+		}
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
-	// access token
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create or sign token", err)
+	// with 2FA on, failures are only forgotten once the code is right too,
+	// knowing the password mustn't buy more guesses at the code
+	if !user.TotpEnabledAt.Valid {
+		err = cfg.clearLoginFailures(r.Context(), params.Email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't clear failed logins", err)
+			return
+		}
+	}
+
+	// the password is only known now, so this is when a hash made with an
+	// older algorithm or weaker parameters can be replaced
+	if needsRehash {
+		err = cfg.rehashPassword(r, user.ID, params.Password)
+		if err != nil {
+			log.Printf("Couldn't rehash password of %s: %s", user.ID, err)
+		}
+	}
+
+	cfg.respondWithFirstFactor(w, r, user)
+}
+
+// respondWithFirstFactor logs in a user who has passed the first factor,
+// or with 2FA on, hands out a token for the second step.
+func (cfg *apiConfig) respondWithFirstFactor(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.TotpEnabledAt.Valid {
+		mfaToken, err := auth.MakeMFAToken(user.ID, cfg.jwtKeys, mfaTokenLifetime)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create or sign token", err)
+			return
+		}
+
+		type response struct {
+			MFARequired bool   `json:"mfa_required"`
+			MFAToken    string `json:"mfa_token"`
+		}
+
+		respondWithJSON(w, http.StatusOK, response{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
 		return
 	}
 
-	// refresh token
-	refreshToken, err := auth.MakeRefreshToken()
+	cfg.respondWithLogin(w, r, user)
+}
+
+// respondWithLogin starts a session for a user who has proven who they are
+// and sends back the user with their access and refresh tokens.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	// logging in during the grace period keeps the account
+	if user.DeletionScheduledAt.Valid {
+		var err error
+		user, err = cfg.db.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't cancel account deletion", err)
+			return
+		}
+	}
+
+	// access token
+	accessToken, err := auth.MakeJWT(user.ID, user.TokenVersion, cfg.jwtKeys, time.Hour)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create or sign token", err)
 		return
 	}
 
-	// store refresh token in database
-	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:  refreshToken,
-		UserID: user.ID,
-	})
+	refreshToken, err := cfg.startSession(r, user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
 		return
 	}
 
@@ -103,65 +189,83 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User:         databaseUserToUser(user),
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	})
 }
 
+func (cfg *apiConfig) rehashPassword(r *http.Request, userID uuid.UUID, password string) error {
+	hashedPw, err := cfg.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return cfg.db.SetUserPasswordHash(r.Context(), database.SetUserPasswordHashParams{
+		ID:             userID,
+		HashedPassword: hashedPw,
+	})
+}
+
+// handlerUpdateEmailAndPassword changes the email, the password or both,
+// whichever are given, after checking the current password.
 func (cfg *apiConfig) handlerUpdateEmailAndPassword(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
 
-	accessTokenHeader, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get refresh token from header", err)
+	if params.Email == "" && params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Nothing to update, send an email or a password", nil)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(accessTokenHeader, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+	user, ok := cfg.authenticateWithPassword(w, r, params.CurrentPassword)
+	if !ok {
 		return
 	}
 
-	hashedPw, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
-		return
+	// the new password is hashed up front, so a password that can't be set
+	// doesn't leave the email changed on its own
+	hashedPw := ""
+	if params.Password != "" {
+		hashedPw, ok = cfg.hashNewPassword(w, params.Password)
+		if !ok {
+			return
+		}
 	}
 
-	user, err := cfg.db.UpdateUserEmailAndPassword(r.Context(), database.UpdateUserEmailAndPasswordParams{
-		ID:             userId,
-		Email:          params.Email,
-		HashedPassword: hashedPw,
-	})
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't update email and password", err)
+	if params.Email != "" {
+		user, ok = cfg.changeEmail(w, r, user, params.Email)
+		if !ok {
+			return
+		}
+	}
+
+	if params.Password == "" {
+		respondWithJSON(w, http.StatusOK, databaseUserToUser(user))
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	cfg.changePassword(w, r, user, hashedPw)
+}
+
+func databaseUserToUser(user database.User) User {
+	return User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/mailer"
+)
+
+// sendVerificationEmail mails the user a link that verifies their current
+// email.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: auth.HashRefreshToken(token, cfg.refreshTokenKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.sendMail(ctx, user.Email, mailer.TemplateVerifyEmail, map[string]any{
+		"Link": cfg.baseURL + "/api/users/verify?token=" + url.QueryEscape(token),
+	})
+}
+
+// sendMail renders the mail template and queues it for to.
+func (cfg *apiConfig) sendMail(ctx context.Context, to, template string, data any) error {
+	msg, err := cfg.mailTemplates.Render(template, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	return cfg.mailer.Send(ctx, msg)
+}
+
+// handlerVerifyEmail verifies an email with the token mailed to it. The
+// token comes from the query string when the link is opened, or from the
+// body when a client posts it.
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{Token: r.URL.Query().Get("token")}
+	if r.Method == http.MethodPost {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+	}
+
+	if params.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "Verification token is missing", nil)
+		return
+	}
+
+	verification, err := cfg.db.UseEmailVerificationToken(r.Context(), auth.HashRefreshToken(params.Token, cfg.refreshTokenKey))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Verification token is invalid or expired", err)
+		return
+	}
+
+	// the email may have changed since the token was sent
+	user, err := cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Verification token is for an old email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseUserToUser(user))
+}
+
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get token from header", err)
+		return
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, loginOnly)
+	if err != nil {
+		respondWithError(w, tokenErrorStatus(err), tokenErrorMessage(err), err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
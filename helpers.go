@@ -1,6 +1,21 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/auth"
+	"github.com/AbdKaan/chirpy/internal/chirptext"
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/passwordpolicy"
+	"github.com/google/uuid"
+)
 
 var profaneWords = map[string]bool{"kerfuffle": true, "sharbert": true, "fornax": true}
 
@@ -15,3 +30,190 @@ func cencorProfane(words string) string {
 
 	return strings.Join(splitWords, " ")
 }
+
+// chirpTextErrorMessage turns a chirptext validation error into the message
+// sent back to the client.
+func chirpTextErrorMessage(err error) string {
+	switch err {
+	case chirptext.ErrEmpty:
+		return "Chirp is empty"
+	case chirptext.ErrTooLong:
+		return "Chirp is too long"
+	case chirptext.ErrInvalidUTF8:
+		return "Chirp is not valid UTF-8"
+	case chirptext.ErrControlCharacter:
+		return "Chirp contains control characters"
+	}
+	return "Chirp is invalid"
+}
+
+// tokenErrorMessage tells the client why its access token was rejected.
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "Token has expired"
+	case errors.Is(err, auth.ErrTokenMalformed):
+		return "Token is malformed"
+	case errors.Is(err, auth.ErrTokenSignatureInvalid):
+		return "Token signature is invalid"
+	case errors.Is(err, auth.ErrTokenRevoked):
+		return "Token has been revoked"
+	case errors.Is(err, auth.ErrTokenClaimsInvalid):
+		return "Token wasn't issued for this service"
+	case errors.Is(err, auth.ErrInsufficientScope):
+		return "Token doesn't have the scope needed for this"
+	}
+	return "Couldn't validate token"
+}
+
+// respondWithPasswordError reports a password the policy rejected along
+// with every rule it breaks.
+func respondWithPasswordError(w http.ResponseWriter, err error) {
+	var invalid *passwordpolicy.ValidationError
+	if !errors.As(err, &invalid) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check password", err)
+		return
+	}
+
+	type errorResponse struct {
+		Error      string                     `json:"error"`
+		Violations []passwordpolicy.Violation `json:"violations"`
+	}
+	respondWithJSON(w, http.StatusBadRequest, errorResponse{
+		Error:      "Password doesn't meet the password policy",
+		Violations: invalid.Violations,
+	})
+}
+
+// extractMentions returns the emails mentioned in a chirp body as
+// "@user@example.com", without duplicates.
+func extractMentions(body string) []string {
+	seen := map[string]bool{}
+	var mentions []string
+	for _, word := range strings.Fields(body) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		email := strings.TrimRight(word[1:], ".,!?:;)")
+		if !strings.Contains(email, "@") || seen[email] {
+			continue
+		}
+		seen[email] = true
+		mentions = append(mentions, email)
+	}
+
+	return mentions
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// viewerID returns the user making the request, or uuid.Nil for anonymous
+// requests. Public chirps don't need a token, so one that doesn't validate
+// is ignored rather than rejected, only a valid one shows the viewer more.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.UUID {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil
+	}
+
+	userId, err := cfg.authenticate(r.Context(), bearerToken, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.Nil
+	}
+	return userId
+}
+
+// loginOnly is the scope of routes that need the access token of a login,
+// like managing sessions, credentials and personal access tokens.
+const loginOnly = ""
+
+// authenticate returns the user a bearer token belongs to. Access tokens
+// from a login can do anything, personal access tokens and tokens issued to
+// OAuth clients need to have been granted scope.
+func (cfg *apiConfig) authenticate(ctx context.Context, bearerToken, scope string) (uuid.UUID, error) {
+	if !auth.IsPAT(bearerToken) {
+		token, err := auth.ValidateAccessToken(ctx, bearerToken, cfg.jwtKeys, cfg.tokenVersions)
+		if err != nil || !token.IsOAuth() {
+			return token.UserID, err
+		}
+		return cfg.authenticateOAuth(ctx, token, scope)
+	}
+
+	pat, err := cfg.db.GetActivePersonalAccessToken(ctx, auth.HashRefreshToken(bearerToken, cfg.refreshTokenKey))
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("%w: unknown, expired or revoked personal access token", auth.ErrTokenRevoked)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !auth.HasScope(pat.Scopes, scope) {
+		return uuid.Nil, fmt.Errorf("%w: %q", auth.ErrInsufficientScope, scope)
+	}
+
+	err = cfg.db.TouchPersonalAccessToken(ctx, pat.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return pat.UserID, nil
+}
+
+// authenticateOAuth accepts an access token issued to an OAuth client while
+// its grant hasn't been revoked and the token has the scope.
+func (cfg *apiConfig) authenticateOAuth(ctx context.Context, token auth.AccessToken, scope string) (uuid.UUID, error) {
+	_, err := cfg.db.GetActiveOAuthGrant(ctx, token.GrantID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("%w: OAuth grant was revoked", auth.ErrTokenRevoked)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !auth.HasScope(token.Scopes, scope) {
+		return uuid.Nil, fmt.Errorf("%w: %q", auth.ErrInsufficientScope, scope)
+	}
+
+	return token.UserID, nil
+}
+
+// tokenErrorStatus is the status code for a rejected token. A valid token
+// that isn't allowed to do something is forbidden rather than unauthorized.
+func tokenErrorStatus(err error) int {
+	if errors.Is(err, auth.ErrInsufficientScope) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// inTx runs fn with queries in a transaction, which is committed when fn
+// returns nil and rolled back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(cfg.db.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// nullTimePtr turns a nullable time into the pointer JSON responses use.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
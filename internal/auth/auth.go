@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
@@ -9,30 +12,42 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPw, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPw), nil
+// Claims are the claims of a Chirpy access token. TokenVersion must match
+// the user's current token version for the token to be accepted. Tokens
+// issued to OAuth clients also name the client and grant and carry the
+// granted scopes, space separated.
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenVersion int32  `json:"ver"`
+	ClientID     string `json:"client_id,omitempty"`
+	GrantID      string `json:"gid,omitempty"`
+	Scope        string `json:"scope,omitempty"`
 }
 
-func CheckPasswordHash(password, hashPw string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashPw), []byte(password))
+func MakeJWT(userID uuid.UUID, tokenVersion int32, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeToken(userID, tokenVersion, keys.Policy.Audience, keys, expiresIn)
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
+func makeToken(userID uuid.UUID, tokenVersion int32, audience string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return signClaims(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		TokenVersion: tokenVersion,
+	}, keys)
+}
 
-	signedToken, err := token.SignedString([]byte(tokenSecret))
+func signClaims(claims Claims, keys *KeySet) (string, error) {
+	token := jwt.NewWithClaims(keys.signing.Method, claims)
+
+	signedToken, err := keys.sign(token)
 	if err != nil {
 		err = fmt.Errorf("signing token: %v", err)
 		return "", err
@@ -40,30 +55,41 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+// ValidateJWT checks the token against the key set's policy and returns the
+// user it was issued to. When versions isn't nil, tokens issued before the
+// user's token version was last incremented are rejected. Errors wrap one
+// of the ErrToken errors. Tokens issued to OAuth clients are rejected with
+// ErrInsufficientScope, they need ValidateAccessToken.
+func ValidateJWT(ctx context.Context, tokenString string, keys *KeySet, versions *TokenVersionCache) (uuid.UUID, error) {
+	token, err := ValidateAccessToken(ctx, tokenString, keys, versions)
 	// handler needs to respond with 401 Unauthorized if there is an error
 	if err != nil {
-		err = fmt.Errorf("parsing claim: %v", err)
 		return uuid.Nil, err
 	}
+	if token.IsOAuth() {
+		return uuid.Nil, fmt.Errorf("%w: token was issued to an OAuth client", ErrInsufficientScope)
+	}
 
-	tokenClaim := jwt.NewWithClaims(jwt.SigningMethodHS256, claims.Claims)
-	userIdStr, err := tokenClaim.Claims.GetSubject()
+	return token.UserID, nil
+}
+
+func parseToken(tokenString string, keys *KeySet, audience string) (Claims, uuid.UUID, error) {
+	policy := keys.Policy
+	policy.Audience = audience
+
+	claims := Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keys.keyFunc, policy.parserOptions()...)
 	if err != nil {
-		err = fmt.Errorf("getting subject: %v", err)
-		return uuid.Nil, err
+		return Claims{}, uuid.Nil, classifyJWTError(err)
 	}
 
-	userId, err := uuid.Parse(userIdStr)
+	userId, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		err = fmt.Errorf("parsing user id: %v", err)
-		return uuid.Nil, err
+		err = fmt.Errorf("%w: parsing user id: %v", ErrTokenClaimsInvalid, err)
+		return Claims{}, uuid.Nil, err
 	}
 
-	return userId, nil
+	return claims, userId, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -91,6 +117,14 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// HashRefreshToken returns the keyed hash refresh tokens are stored and
+// looked up by, so a leaked database doesn't leak usable tokens.
+func HashRefreshToken(token, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	headerAuth := headers.Get("Authorization")
 	if len(headerAuth) <= 7 {
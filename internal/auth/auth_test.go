@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
@@ -32,14 +34,16 @@ func TestHashPassword(t *testing.T) {
 
 func TestJWT(t *testing.T) {
 	id := uuid.New()
-	tokenSecret := "verysecret"
-	tokenStr, err := MakeJWT(id, tokenSecret, time.Second*3)
+	keys := NewHMACKeySet("verysecret")
+	// no clock skew allowance so the token expires right on time
+	keys.Policy.Leeway = 0
+	tokenStr, err := MakeJWT(id, 0, keys, time.Second*3)
 	if err != nil {
 		t.Errorf("trying to make jwt: %v", err)
 	}
 
 	// validate
-	validatedUuid, err := ValidateJWT(tokenStr, tokenSecret)
+	validatedUuid, err := ValidateJWT(context.Background(), tokenStr, keys, nil)
 	if err != nil {
 		t.Errorf("validating jwt: %v", err)
 	}
@@ -49,19 +53,51 @@ func TestJWT(t *testing.T) {
 	}
 
 	// shouldn't be validated with wrong secret key
-	_, err = ValidateJWT(tokenStr, "fakenews")
+	_, err = ValidateJWT(context.Background(), tokenStr, NewHMACKeySet("fakenews"), nil)
 	if err == nil {
 		t.Errorf("error is nil with wrong token secret: %v", err)
 	}
 
 	time.Sleep(3 * time.Second)
 	// token should be expired
-	_, err = ValidateJWT(tokenStr, tokenSecret)
+	_, err = ValidateJWT(context.Background(), tokenStr, keys, nil)
 	if err == nil {
 		t.Errorf("token should be expired but error is nil: %v", err)
 	}
 }
 
+func TestJWTTokenVersion(t *testing.T) {
+	id := uuid.New()
+	keys := NewHMACKeySet("verysecret")
+	currentVersion := int32(1)
+	lookups := 0
+	versions := NewTokenVersionCache(func(ctx context.Context, userID uuid.UUID) (int32, error) {
+		lookups++
+		return currentVersion, nil
+	}, time.Minute)
+
+	tokenStr, err := MakeJWT(id, 1, keys, time.Minute)
+	if err != nil {
+		t.Errorf("trying to make jwt: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := ValidateJWT(context.Background(), tokenStr, keys, versions); err != nil {
+			t.Errorf("validating jwt: %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("token version should be cached, looked up %d times", lookups)
+	}
+
+	// revoke every token issued so far
+	currentVersion = 2
+	versions.Invalidate(id)
+	if _, err := ValidateJWT(context.Background(), tokenStr, keys, versions); err == nil {
+		t.Errorf("token with an old version should be rejected")
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	token := "Bearer mytoken"
 	header := http.Header{"Authorization": {token}}
@@ -74,3 +110,146 @@ func TestGetBearerToken(t *testing.T) {
 		t.Errorf("found tokens are not equal, %s != %s", bearerToken, token)
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Errorf("making refresh token: %v", err)
+	}
+
+	hash := HashRefreshToken(token, "key")
+	if hash == token {
+		t.Errorf("hash is the raw token")
+	}
+	if hash != HashRefreshToken(token, "key") {
+		t.Errorf("hashing the same token twice gave different hashes")
+	}
+	if hash == HashRefreshToken(token, "otherkey") {
+		t.Errorf("hashes with different keys are equal")
+	}
+}
+
+func TestValidateJWTErrors(t *testing.T) {
+	id := uuid.New()
+	keys := NewHMACKeySet("verysecret")
+
+	expired, err := MakeJWT(id, 0, keys, -time.Hour)
+	if err != nil {
+		t.Errorf("trying to make jwt: %v", err)
+	}
+	if _, err := ValidateJWT(context.Background(), expired, keys, nil); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got: %v", err)
+	}
+
+	valid, err := MakeJWT(id, 0, keys, time.Hour)
+	if err != nil {
+		t.Errorf("trying to make jwt: %v", err)
+	}
+	if _, err := ValidateJWT(context.Background(), valid, NewHMACKeySet("fakenews"), nil); !errors.Is(err, ErrTokenSignatureInvalid) {
+		t.Errorf("expected ErrTokenSignatureInvalid, got: %v", err)
+	}
+
+	if _, err := ValidateJWT(context.Background(), "not.a.jwt", keys, nil); !errors.Is(err, ErrTokenMalformed) {
+		t.Errorf("expected ErrTokenMalformed, got: %v", err)
+	}
+
+	otherAudience := NewHMACKeySet("verysecret")
+	otherAudience.Policy.Audience = "someone-else"
+	if _, err := ValidateJWT(context.Background(), valid, otherAudience, nil); !errors.Is(err, ErrTokenClaimsInvalid) {
+		t.Errorf("expected ErrTokenClaimsInvalid for the wrong audience, got: %v", err)
+	}
+
+	onlyRSA := NewHMACKeySet("verysecret")
+	onlyRSA.Policy.Algorithms = []string{"RS256"}
+	if _, err := ValidateJWT(context.Background(), valid, onlyRSA, nil); err == nil {
+		t.Errorf("token signed with a disallowed algorithm should be rejected")
+	}
+}
+
+func TestPATScopes(t *testing.T) {
+	token, err := MakePAT()
+	if err != nil {
+		t.Fatalf("making token: %v", err)
+	}
+	if !IsPAT(token) {
+		t.Errorf("IsPAT(%q) = false, want true", token)
+	}
+	if IsPAT("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Errorf("IsPAT accepted a JWT")
+	}
+
+	granted := []string{ScopeChirpsRead}
+	if !HasScope(granted, ScopeChirpsRead) {
+		t.Errorf("HasScope(%v, %q) = false, want true", granted, ScopeChirpsRead)
+	}
+	if HasScope(granted, ScopeChirpsWrite) {
+		t.Errorf("HasScope(%v, %q) = true, want false", granted, ScopeChirpsWrite)
+	}
+	if HasScope(Scopes, "") {
+		t.Errorf("HasScope granted a login-only route")
+	}
+}
+
+func TestOAuthToken(t *testing.T) {
+	keys := NewHMACKeySet("verysecret")
+	userID, clientID, grantID := uuid.New(), uuid.New(), uuid.New()
+	scopes := []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+	tokenStr, err := MakeOAuthToken(userID, 0, clientID, grantID, scopes, keys, time.Hour)
+	if err != nil {
+		t.Fatalf("making token: %v", err)
+	}
+
+	token, err := ValidateAccessToken(context.Background(), tokenStr, keys, nil)
+	if err != nil {
+		t.Fatalf("validating token: %v", err)
+	}
+	if !token.IsOAuth() || token.UserID != userID || token.ClientID != clientID || token.GrantID != grantID {
+		t.Errorf("token = %+v", token)
+	}
+	if len(token.Scopes) != 2 || token.Scopes[0] != ScopeChirpsRead || token.Scopes[1] != ScopeChirpsWrite {
+		t.Errorf("scopes = %v, want %v", token.Scopes, scopes)
+	}
+
+	// OAuth tokens must not pass for unscoped login tokens
+	_, err = ValidateJWT(context.Background(), tokenStr, keys, nil)
+	if !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("expected ErrInsufficientScope from ValidateJWT, got: %v", err)
+	}
+
+	loginToken, _ := MakeJWT(userID, 0, keys, time.Hour)
+	token, err = ValidateAccessToken(context.Background(), loginToken, keys, nil)
+	if err != nil || token.IsOAuth() {
+		t.Errorf("login token = %+v, err = %v", token, err)
+	}
+}
+
+func TestLinkToken(t *testing.T) {
+	keys := NewHMACKeySet("verysecret")
+	link := PendingLink{UserID: uuid.New(), Provider: "google", Issuer: "https://accounts.example.com", Subject: "1234", Email: "user@example.com"}
+
+	tokenStr, err := MakeLinkToken(link, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("making link token: %v", err)
+	}
+
+	confirmed, err := ValidateLinkToken(tokenStr, keys, link.UserID)
+	if err != nil {
+		t.Fatalf("validating link token: %v", err)
+	}
+	if confirmed != link {
+		t.Errorf("link = %+v, want %+v", confirmed, link)
+	}
+
+	// a link URL sent to someone else comes back with their sign-in, it
+	// mustn't be confirmed by anyone but the user who started it
+	_, err = ValidateLinkToken(tokenStr, keys, uuid.New())
+	if !errors.Is(err, ErrTokenClaimsInvalid) {
+		t.Errorf("expected ErrTokenClaimsInvalid for another user, got: %v", err)
+	}
+
+	// nor does it pass for an access token
+	if _, err := ValidateJWT(context.Background(), tokenStr, keys, nil); err == nil {
+		t.Errorf("link token shouldn't be accepted as an access token")
+	}
+}
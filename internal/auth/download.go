@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DownloadAudience is the audience of download links, which carry a token
+// in the URL since a browser following a link can't send a bearer token.
+// The token's subject is the thing being downloaded, not a user.
+const DownloadAudience = "chirpy-download"
+
+func MakeDownloadToken(id uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeToken(id, 0, DownloadAudience, keys, expiresIn)
+}
+
+func ValidateDownloadToken(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	_, id, err := parseToken(tokenString, keys, DownloadAudience)
+	return id, err
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a key access tokens are signed or verified with. Keys that only
+// verify have no private part.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	privateKey interface{}
+	publicKey  interface{}
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are
+// still accepted from. Rotating keys means signing with a new key while the
+// old one stays in the set until the tokens it signed have expired. Tokens
+// are issued for and validated against Policy.
+type KeySet struct {
+	Policy Policy
+
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewHMACKey is a shared secret key. HMAC keys have no key ID and are
+// never published in the JWKS.
+func NewHMACKey(secret string) *Key {
+	return &Key{
+		ID:         "",
+		Method:     jwt.SigningMethodHS256,
+		privateKey: []byte(secret),
+		publicKey:  []byte(secret),
+	}
+}
+
+// NewKeySet signs with signing and also accepts tokens signed by any of
+// the verification keys.
+func NewKeySet(signing *Key, verification ...*Key) (*KeySet, error) {
+	if signing.privateKey == nil {
+		return nil, errors.New("signing key has no private key")
+	}
+
+	ks := &KeySet{
+		Policy:  DefaultPolicy,
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verification {
+		ks.keys[key.ID] = key
+	}
+	return ks, nil
+}
+
+// NewHMACKeySet signs and verifies tokens with a single shared secret.
+func NewHMACKeySet(secret string) *KeySet {
+	ks, _ := NewKeySet(NewHMACKey(secret))
+	return ks
+}
+
+// LoadKeyFile reads a PEM encoded RSA or Ed25519 key. Private keys can sign
+// and verify, public keys can only verify. The key ID is the key's RFC 7638
+// thumbprint.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return newKey(parsed)
+}
+
+func newKey(parsed interface{}) (*Key, error) {
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.privateKey = k
+		key.publicKey = &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+		key.publicKey = k
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.privateKey = k
+		key.publicKey = k.Public()
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.publicKey = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	thumbprint, err := json.Marshal(key.jwk(false))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(thumbprint)
+	key.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return key, nil
+}
+
+// JWK is a public key as published in a JWKS. Fields are in the order RFC
+// 7638 needs for thumbprints.
+type JWK struct {
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Kty string `json:"kty"`
+	N   string `json:"n,omitempty"`
+	Use string `json:"use,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwk describes the public part of the key, with only the members used for
+// thumbprints unless full is set.
+func (k *Key) jwk(full bool) JWK {
+	jwk := JWK{}
+	switch pub := k.publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	if full {
+		jwk.Kid = k.ID
+		jwk.Alg = k.Method.Alg()
+		jwk.Use = "sig"
+	}
+	return jwk
+}
+
+// JWKS lists the public keys in the set so other services can verify
+// tokens. Shared secrets are left out.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if key.Method == jwt.SigningMethodHS256 {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, key.jwk(true))
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
+
+// keyFunc picks the verification key named by the token's kid and makes
+// sure the token was signed with that key's algorithm.
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q doesn't sign with %s", kid, t.Method.Alg())
+	}
+
+	return key.publicKey, nil
+}
+
+// sign signs the token with the current signing key.
+func (ks *KeySet) sign(token *jwt.Token) (string, error) {
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+	return token.SignedString(ks.signing.privateKey)
+}
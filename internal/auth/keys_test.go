@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func writeKeyFile(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	return path
+}
+
+func TestKeyRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+
+	oldKey, err := LoadKeyFile(writeKeyFile(t, rsaKey))
+	if err != nil {
+		t.Fatalf("loading rsa key: %v", err)
+	}
+	newKey, err := LoadKeyFile(writeKeyFile(t, edKey))
+	if err != nil {
+		t.Fatalf("loading ed25519 key: %v", err)
+	}
+
+	id := uuid.New()
+	oldKeys, _ := NewKeySet(oldKey)
+	oldToken, err := MakeJWT(id, 0, oldKeys, time.Minute)
+	if err != nil {
+		t.Errorf("signing with rsa key: %v", err)
+	}
+
+	// sign with the new key but keep accepting the old one
+	keys, err := NewKeySet(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("making key set: %v", err)
+	}
+	newToken, err := MakeJWT(id, 0, keys, time.Minute)
+	if err != nil {
+		t.Errorf("signing with ed25519 key: %v", err)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		validatedID, err := ValidateJWT(context.Background(), token, keys, nil)
+		if err != nil {
+			t.Errorf("validating token: %v", err)
+		}
+		if validatedID != id {
+			t.Errorf("validated id is not equal to the original")
+		}
+	}
+
+	if _, err := ValidateJWT(context.Background(), newToken, oldKeys, nil); err == nil {
+		t.Errorf("token signed by an unknown key should be rejected")
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Errorf("expected 2 keys in the JWKS, got %d", len(jwks.Keys))
+	}
+}
+
+func TestHMACKeysStayPrivate(t *testing.T) {
+	keys := NewHMACKeySet("verysecret")
+	if len(keys.JWKS().Keys) != 0 {
+		t.Errorf("HMAC keys shouldn't be published")
+	}
+}
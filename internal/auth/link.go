@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// LinkAudience is the audience of the tokens handed out when a sign-in to
+// link an identity comes back from the provider. The identity is only
+// linked once the user who started the link confirms it with the token,
+// so a link URL opened by someone else links nothing.
+const LinkAudience = "chirpy-link"
+
+// PendingLink is an identity waiting to be linked to UserID.
+type PendingLink struct {
+	UserID   uuid.UUID
+	Provider string
+	Issuer   string
+	Subject  string
+	Email    string
+}
+
+type linkClaims struct {
+	jwt.RegisteredClaims
+	Provider        string `json:"provider"`
+	IdentityIssuer  string `json:"identity_iss"`
+	IdentitySubject string `json:"identity_sub"`
+	Email           string `json:"email,omitempty"`
+}
+
+func MakeLinkToken(link PendingLink, keys *KeySet, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(keys.signing.Method, linkClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{LinkAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   link.UserID.String(),
+		},
+		Provider:        link.Provider,
+		IdentityIssuer:  link.Issuer,
+		IdentitySubject: link.Subject,
+		Email:           link.Email,
+	})
+
+	signedToken, err := keys.sign(token)
+	if err != nil {
+		return "", fmt.Errorf("signing token: %v", err)
+	}
+	return signedToken, nil
+}
+
+// ValidateLinkToken returns the identity a link token is for, as long as
+// userID started the link.
+func ValidateLinkToken(tokenString string, keys *KeySet, userID uuid.UUID) (PendingLink, error) {
+	policy := keys.Policy
+	policy.Audience = LinkAudience
+
+	claims := linkClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keys.keyFunc, policy.parserOptions()...)
+	if err != nil {
+		return PendingLink{}, classifyJWTError(err)
+	}
+
+	linkUserID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return PendingLink{}, fmt.Errorf("%w: parsing user id: %v", ErrTokenClaimsInvalid, err)
+	}
+	if linkUserID != userID {
+		return PendingLink{}, fmt.Errorf("%w: link was started by another user", ErrTokenClaimsInvalid)
+	}
+
+	return PendingLink{
+		UserID:   linkUserID,
+		Provider: claims.Provider,
+		Issuer:   claims.IdentityIssuer,
+		Subject:  claims.IdentitySubject,
+		Email:    claims.Email,
+	}, nil
+}
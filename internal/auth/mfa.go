@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAAudience is the audience of the short-lived tokens handed out after a
+// correct password when a second factor is still needed. Having a different
+// audience keeps them from being used as access tokens.
+const MFAAudience = "chirpy-mfa"
+
+func MakeMFAToken(userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeToken(userID, 0, MFAAudience, keys, expiresIn)
+}
+
+// ValidateMFAToken returns the user an MFA token was made for and the
+// token's ID, so wrong codes entered with it can be counted against it.
+func ValidateMFAToken(tokenString string, keys *KeySet) (uuid.UUID, uuid.UUID, error) {
+	claims, userId, err := parseToken(tokenString, keys, MFAAudience)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("%w: parsing token id: %v", ErrTokenClaimsInvalid, err)
+	}
+
+	return userId, tokenID, nil
+}
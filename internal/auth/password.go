@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms a PasswordHasher can hash with. Hashes made
+// with either can always be checked.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password doesn't match")
+	ErrUnknownPasswordHash = errors.New("password hash is in an unknown format")
+)
+
+// Argon2Params are the cost parameters of Argon2id. They are stored in
+// every hash, so changing them only affects new hashes.
+type Argon2Params struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for Argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordHasher hashes new passwords with Algorithm and checks passwords
+// against hashes of any algorithm, telling when a hash was made with other
+// parameters and should be replaced.
+type PasswordHasher struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewPasswordHasher(algorithm string, argon2Params Argon2Params, bcryptCost int) (*PasswordHasher, error) {
+	h := &PasswordHasher{
+		Algorithm:  algorithm,
+		Argon2:     argon2Params,
+		BcryptCost: bcryptCost,
+	}
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		p := argon2Params
+		if p.Memory < 8*uint32(p.Parallelism) || p.Iterations < 1 || p.Parallelism < 1 || p.SaltLength < 8 || p.KeyLength < 16 {
+			return nil, fmt.Errorf("argon2id parameters are too weak: %+v", p)
+		}
+	case AlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", algorithm)
+	}
+
+	return h, nil
+}
+
+// DefaultPasswordHasher hashes with Argon2id using DefaultArgon2Params.
+var DefaultPasswordHasher, _ = NewPasswordHasher(AlgorithmArgon2id, DefaultArgon2Params, bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordHasher.Hash(password)
+}
+
+func CheckPasswordHash(password, hashPw string) error {
+	_, err := DefaultPasswordHasher.Check(password, hashPw)
+	return err
+}
+
+// Hash returns the password's hash with the algorithm and parameters
+// encoded in it, Argon2id hashes in the PHC string format.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == AlgorithmBcrypt {
+		hashedPw, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashedPw), nil
+	}
+
+	p := h.Argon2
+	salt := make([]byte, p.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Check returns nil if password matches hashPw. needsRehash is set when the
+// password matches but hashPw wasn't made with the hasher's algorithm and
+// parameters, the caller should then store a new hash.
+func (h *PasswordHasher) Check(password, hashPw string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hashPw, "$argon2id$"):
+		p, salt, key, err := decodeArgon2Hash(hashPw)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		return h.Algorithm != AlgorithmArgon2id || p != h.Argon2, nil
+
+	case strings.HasPrefix(hashPw, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hashPw), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, ErrPasswordMismatch
+		}
+		if err != nil {
+			return false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(hashPw))
+		if err != nil {
+			return false, err
+		}
+		return h.Algorithm != AlgorithmBcrypt || cost != h.BcryptCost, nil
+	}
+
+	return false, ErrUnknownPasswordHash
+}
+
+// CheckDummy takes as long as Check, for when there is no user to check a
+// password against. That way a login can't tell an unknown email from a
+// wrong password by how long it took.
+func (h *PasswordHasher) CheckDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = h.Hash("chirpy")
+	})
+	h.Check(password, h.dummyHash)
+}
+
+func decodeArgon2Hash(hashPw string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hashPw, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: unsupported argon2 version %q", ErrUnknownPasswordHash, parts[2])
+	}
+
+	p := Argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %v", ErrUnknownPasswordHash, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %v", ErrUnknownPasswordHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: %v", ErrUnknownPasswordHash, err)
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPasswordRehash(t *testing.T) {
+	weak := Argon2Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	strong := Argon2Params{Memory: 16 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	bcryptHasher, err := NewPasswordHasher(AlgorithmBcrypt, weak, 4)
+	if err != nil {
+		t.Fatalf("creating bcrypt hasher: %v", err)
+	}
+	weakHasher, err := NewPasswordHasher(AlgorithmArgon2id, weak, 4)
+	if err != nil {
+		t.Fatalf("creating argon2id hasher: %v", err)
+	}
+	strongHasher, err := NewPasswordHasher(AlgorithmArgon2id, strong, 4)
+	if err != nil {
+		t.Fatalf("creating argon2id hasher: %v", err)
+	}
+
+	bcryptHash, err := bcryptHasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("hashing: %v", err)
+	}
+	weakHash, err := weakHasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("hashing: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		hasher      *PasswordHasher
+		hash        string
+		needsRehash bool
+	}{
+		{"bcrypt with bcrypt", bcryptHasher, bcryptHash, false},
+		{"bcrypt with argon2id", weakHasher, bcryptHash, true},
+		{"same argon2id parameters", weakHasher, weakHash, false},
+		{"stronger argon2id parameters", strongHasher, weakHash, true},
+		{"argon2id with bcrypt", bcryptHasher, weakHash, true},
+	}
+
+	for _, tt := range tests {
+		needsRehash, err := tt.hasher.Check("hunter2", tt.hash)
+		if err != nil {
+			t.Errorf("%s: checking the right password: %v", tt.name, err)
+		}
+		if needsRehash != tt.needsRehash {
+			t.Errorf("%s: needsRehash = %v, want %v", tt.name, needsRehash, tt.needsRehash)
+		}
+
+		if _, err := tt.hasher.Check("hunter3", tt.hash); !errors.Is(err, ErrPasswordMismatch) {
+			t.Errorf("%s: expected ErrPasswordMismatch for the wrong password, got: %v", tt.name, err)
+		}
+	}
+
+	if _, err := weakHasher.Check("hunter2", "plaintext"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("expected ErrUnknownPasswordHash, got: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+// PATPrefix starts every personal access token, so they can be told apart
+// from JWTs and spotted by secret scanners.
+const PATPrefix = "chirpy_pat_"
+
+// Scopes a personal access token or OAuth client can be granted. Access
+// tokens from a login are not scoped and can do everything.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+// ErrInsufficientScope is returned when a personal access token or OAuth
+// access token is used for something it wasn't granted.
+var ErrInsufficientScope = errors.New("token is missing the required scope")
+
+func MakePAT() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	return PATPrefix + token, nil
+}
+
+func IsPAT(token string) bool {
+	return strings.HasPrefix(token, PATPrefix)
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scope is one of granted. The empty scope marks
+// routes that only a logged in user may use, so no token has it.
+func HasScope(granted []string, scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, s := range granted {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer is the iss claim of every token Chirpy issues, tokens from anyone
+// else are rejected.
+const Issuer = "chirpy"
+
+// Policy is what ValidateJWT requires of a token besides a valid signature.
+type Policy struct {
+	// Algorithms are the only signing algorithms accepted, whatever keys
+	// are in the key set.
+	Algorithms []string
+	// Audience must be one of the token's aud claims, tokens are issued
+	// for it.
+	Audience string
+	// Leeway is how much clock skew is tolerated when checking exp, nbf
+	// and iat.
+	Leeway time.Duration
+}
+
+var DefaultPolicy = Policy{
+	Algorithms: []string{"EdDSA", "RS256", "HS256"},
+	Audience:   "chirpy",
+	Leeway:     30 * time.Second,
+}
+
+// Errors returned by ValidateJWT, check them with errors.Is.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenExpired          = errors.New("token has expired")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenClaimsInvalid    = errors.New("token claims are invalid")
+	ErrTokenRevoked          = errors.New("token has been revoked")
+)
+
+func (p Policy) parserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithValidMethods(p.Algorithms),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(p.Audience),
+		jwt.WithLeeway(p.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+}
+
+// classifyJWTError maps an error from the jwt package onto one of the
+// errors above.
+func classifyJWTError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %v", ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %v", ErrTokenSignatureInvalid, err)
+	}
+	return fmt.Errorf("%w: %v", ErrTokenClaimsInvalid, err)
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenVersionFunc looks up the current token version of a user.
+type TokenVersionFunc func(ctx context.Context, userID uuid.UUID) (int32, error)
+
+// TokenVersionCache remembers token versions for a while so validating an
+// access token doesn't need a database query on every request. Whoever
+// increments a version must call Invalidate, other instances of the server
+// pick the change up once their entry expires.
+type TokenVersionCache struct {
+	lookup TokenVersionFunc
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]tokenVersionEntry
+}
+
+// maxTokenVersionEntries is when expired entries start being cleaned up.
+const maxTokenVersionEntries = 10000
+
+type tokenVersionEntry struct {
+	version   int32
+	expiresAt time.Time
+}
+
+func NewTokenVersionCache(lookup TokenVersionFunc, ttl time.Duration) *TokenVersionCache {
+	return &TokenVersionCache{
+		lookup:  lookup,
+		ttl:     ttl,
+		entries: map[uuid.UUID]tokenVersionEntry{},
+	}
+}
+
+func (c *TokenVersionCache) Get(ctx context.Context, userID uuid.UUID) (int32, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.version, nil
+	}
+
+	version, err := c.lookup(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxTokenVersionEntries {
+		c.dropExpired()
+	}
+	c.entries[userID] = tokenVersionEntry{
+		version:   version,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	c.mu.Unlock()
+
+	return version, nil
+}
+
+func (c *TokenVersionCache) Invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// dropExpired removes stale entries, c.mu must be held.
+func (c *TokenVersionCache) dropExpired() {
+	now := time.Now()
+	for userID, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, userID)
+		}
+	}
+}
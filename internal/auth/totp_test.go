@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vectors for SHA1, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, test := range tests {
+		code, err := TOTPCode(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Errorf("making code: %v", err)
+		}
+		if code != test.code {
+			t.Errorf("code at %d is %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := MakeTOTPSecret()
+	if err != nil {
+		t.Fatalf("making secret: %v", err)
+	}
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("making code: %v", err)
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now.Add(30*time.Second)); !ok {
+		t.Errorf("code from the previous period should be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(5*time.Minute)); ok {
+		t.Errorf("old code should be rejected")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key := EncryptionKey("verysecret")
+	ciphertext, err := Encrypt("JBSWY3DPEHPK3PXP", key)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+
+	plaintext, err := Decrypt(ciphertext, key)
+	if err != nil {
+		t.Errorf("decrypting: %v", err)
+	}
+	if plaintext != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("decrypted %q", plaintext)
+	}
+
+	if _, err := Decrypt(ciphertext, EncryptionKey("fakenews")); err == nil {
+		t.Errorf("decrypting with the wrong key should fail")
+	}
+}
+
+func TestMFAToken(t *testing.T) {
+	id := uuid.New()
+	keys := NewHMACKeySet("verysecret")
+
+	mfaToken, err := MakeMFAToken(id, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("making mfa token: %v", err)
+	}
+
+	validatedID, tokenID, err := ValidateMFAToken(mfaToken, keys)
+	if err != nil {
+		t.Errorf("validating mfa token: %v", err)
+	}
+	if validatedID != id {
+		t.Errorf("validated id is not equal to the original")
+	}
+
+	otherToken, err := MakeMFAToken(id, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("making mfa token: %v", err)
+	}
+	_, otherTokenID, err := ValidateMFAToken(otherToken, keys)
+	if err != nil {
+		t.Errorf("validating mfa token: %v", err)
+	}
+	if tokenID == uuid.Nil || tokenID == otherTokenID {
+		t.Errorf("mfa tokens should have their own ids, got %v and %v", tokenID, otherTokenID)
+	}
+
+	if _, err := ValidateJWT(context.Background(), mfaToken, keys, nil); err == nil {
+		t.Errorf("mfa token shouldn't be accepted as an access token")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("making recovery codes: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			t.Errorf("duplicate recovery code %s", code)
+		}
+		seen[code] = true
+	}
+
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(codes[0])) {
+		t.Errorf("recovery codes should match regardless of case and spacing")
+	}
+}
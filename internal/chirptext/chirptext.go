@@ -0,0 +1,79 @@
+package chirptext
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLLength is how many characters a link counts as, whatever its real
+// length, so shortened and long links cost the same.
+const URLLength = 23
+
+var (
+	ErrEmpty            = errors.New("chirp is empty")
+	ErrTooLong          = errors.New("chirp is too long")
+	ErrInvalidUTF8      = errors.New("chirp is not valid UTF-8")
+	ErrControlCharacter = errors.New("chirp contains control characters")
+)
+
+var urlRegexp = regexp.MustCompile(`https?://\S+`)
+
+// Normalize puts text into NFC form, unifies line endings and trims
+// surrounding whitespace. Control characters other than newlines and tabs
+// are rejected.
+func Normalize(text string) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", ErrInvalidUTF8
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, r := range text {
+		if r == '\n' || r == '\t' {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return "", ErrControlCharacter
+		}
+	}
+
+	return strings.TrimSpace(norm.NFC.String(text)), nil
+}
+
+// Length counts the user-perceived characters (grapheme clusters) in text,
+// with every URL counted as URLLength characters.
+func Length(text string) int {
+	length := 0
+	last := 0
+	for _, loc := range urlRegexp.FindAllStringIndex(text, -1) {
+		length += uniseg.GraphemeClusterCount(text[last:loc[0]]) + URLLength
+		last = loc[1]
+	}
+
+	return length + uniseg.GraphemeClusterCount(text[last:])
+}
+
+// Validate normalizes text and checks that it is neither empty nor longer
+// than maxLength. It returns the normalized text, which is what should be
+// stored.
+func Validate(text string, maxLength int) (string, error) {
+	text, err := Normalize(text)
+	if err != nil {
+		return "", err
+	}
+
+	if text == "" {
+		return "", ErrEmpty
+	}
+
+	if Length(text) > maxLength {
+		return "", ErrTooLong
+	}
+
+	return text, nil
+}
@@ -0,0 +1,63 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"hello", 5},
+		{"héllo", 5},
+		{"é", 1},
+		{"👍🏽", 1},
+		{"👨‍👩‍👧", 1},
+		{"🇹🇷", 1},
+		{"こんにちは", 5},
+		{"see https://example.com/a/very/long/path/that/goes/on", 4 + URLLength},
+	}
+
+	for _, test := range tests {
+		if got := Length(test.text); got != test.want {
+			t.Errorf("Length(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize("  é\r\nok \t")
+	if err != nil {
+		t.Errorf("normalizing: %v", err)
+	}
+	if normalized != "é\nok" {
+		t.Errorf("normalized text is %q", normalized)
+	}
+
+	_, err = Normalize("bell\a")
+	if err != ErrControlCharacter {
+		t.Errorf("control character should be rejected, got: %v", err)
+	}
+
+	_, err = Normalize("\xff")
+	if err != ErrInvalidUTF8 {
+		t.Errorf("invalid UTF-8 should be rejected, got: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	emojis := strings.Repeat("🐦", 50)
+	if _, err := Validate(emojis, 140); err != nil {
+		t.Errorf("50 emojis should fit in 140 characters: %v", err)
+	}
+
+	if _, err := Validate(strings.Repeat("a", 141), 140); err != ErrTooLong {
+		t.Errorf("141 characters should be too long, got: %v", err)
+	}
+
+	if _, err := Validate(" \n ", 140); err != ErrEmpty {
+		t.Errorf("whitespace should be empty, got: %v", err)
+	}
+}
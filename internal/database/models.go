@@ -11,28 +11,239 @@ import (
 	"github.com/google/uuid"
 )
 
-type Post struct {
+type EmailVerificationToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type ExportArchive struct {
+	ExportJobID uuid.UUID
+	Data        []byte
+}
+
+type ExportJob struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	Error       sql.NullString
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type ImportItemError struct {
+	ImportJobID uuid.UUID
+	Item        int32
+	Message     string
+}
+
+type ImportJob struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	Error       sql.NullString
+	Total       int32
+	Imported    int32
+	Skipped     int32
+	Failed      int32
+	CompletedAt sql.NullTime
+}
+
+type ImportUpload struct {
+	ImportJobID uuid.UUID
+	Data        []byte
+}
+
+type LoginFailure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Email     string
+	IpAddress string
+}
+
+type MfaFailure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	TokenID   uuid.UUID
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+	GrantID       uuid.NullUUID
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type OauthGrant struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	ClientID         uuid.UUID
+	UserID           uuid.UUID
+	Scopes           []string
+	RefreshTokenHash string
+	LastUsedAt       sql.NullTime
+	RevokedAt        sql.NullTime
+}
+
+type OauthUsedRefreshToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	GrantID   uuid.UUID
+}
+
+type OidcLoginState struct {
+	StateHash    string
+	CreatedAt    time.Time
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       uuid.NullUUID
+	ExpiresAt    time.Time
+}
+
+type OutboxMessage struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ToAddress     string
+	Subject       string
+	TextBody      string
+	HtmlBody      string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	SentAt        sql.NullTime
+	FailedAt      sql.NullTime
+}
+
+type PasswordResetRequest struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Email     string
+	IpAddress string
+}
+
+type PasswordResetToken struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type Post struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	Visibility string
+	PinnedAt   sql.NullTime
+	ExpiresAt  sql.NullTime
+	ImportKey  sql.NullString
+}
+
+type PostMention struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
 }
 
 type RefreshToken struct {
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+}
+
+type Session struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	IpAddress  string
+	UserAgent  string
+	LastUsedAt time.Time
+	RevokedAt  sql.NullTime
+}
+
+type TotpRecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	TokenVersion        int32
+	TotpSecret          sql.NullString
+	TotpEnabledAt       sql.NullTime
+	TotpLastStep        int64
+	EmailVerifiedAt     sql.NullTime
+	DeletionScheduledAt sql.NullTime
+}
+
+type UserIdentity struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Provider    string
+	Issuer      string
+	Subject     string
+	Email       string
+	LastLoginAt sql.NullTime
 }
@@ -7,29 +7,86 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const clearPostExpiry = `-- name: ClearPostExpiry :one
+UPDATE posts SET expires_at = NULL,
+updated_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
+`
+
+func (q *Queries) ClearPostExpiry(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, clearPostExpiry, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const countPinnedPostsOfAuthor = `-- name: CountPinnedPostsOfAuthor :one
+SELECT COUNT(*) FROM posts
+WHERE user_id = $1
+AND pinned_at IS NOT NULL
+AND (expires_at IS NULL OR expires_at > Now())
+`
+
+func (q *Queries) CountPinnedPostsOfAuthor(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedPostsOfAuthor, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPostsOfAuthorInLastHour = `-- name: CountPostsOfAuthorInLastHour :one
+SELECT COUNT(*) FROM posts
+WHERE user_id = $1
+AND created_at > Now() - INTERVAL '1 hour'
+`
+
+func (q *Queries) CountPostsOfAuthorInLastHour(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsOfAuthorInLastHour, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, body, user_id)
+INSERT INTO posts (id, created_at, updated_at, body, user_id, visibility, expires_at)
 VALUES (
     gen_random_uuid(),
     Now(),
     Now(),
     $1,
-    $2
+    $2,
+    $3,
+    Now() + make_interval(secs => $4::float8)
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
 `
 
 type CreatePostParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body             string
+	UserID           uuid.UUID
+	Visibility       string
+	ExpiresInSeconds sql.NullFloat64
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createPost, arg.Body, arg.UserID, arg.Visibility, arg.ExpiresInSeconds)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -37,10 +94,43 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
 	)
 	return i, err
 }
 
+const createPostMention = `-- name: CreatePostMention :exec
+INSERT INTO post_mentions (post_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type CreatePostMentionParams struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) CreatePostMention(ctx context.Context, arg CreatePostMentionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostMention, arg.PostID, arg.UserID)
+	return err
+}
+
+const deleteExpiredPosts = `-- name: DeleteExpiredPosts :execrows
+DELETE FROM posts
+WHERE expires_at <= Now()
+`
+
+func (q *Queries) DeleteExpiredPosts(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredPosts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deletePost = `-- name: DeletePost :exec
 DELETE FROM posts
 WHERE id = $1
@@ -51,8 +141,124 @@ func (q *Queries) DeletePost(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getAllPostsOfAuthor = `-- name: GetAllPostsOfAuthor :many
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetAllPostsOfAuthor(ctx context.Context, userID uuid.UUID) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPostsOfAuthor, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Visibility,
+			&i.PinnedAt,
+			&i.ExpiresAt,
+			&i.ImportKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMentionsInPostsOfAuthor = `-- name: GetMentionsInPostsOfAuthor :many
+SELECT post_mentions.post_id, post_mentions.user_id FROM post_mentions
+JOIN posts ON posts.id = post_mentions.post_id
+WHERE posts.user_id = $1
+`
+
+type GetMentionsInPostsOfAuthorRow struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetMentionsInPostsOfAuthor(ctx context.Context, userID uuid.UUID) ([]GetMentionsInPostsOfAuthorRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMentionsInPostsOfAuthor, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMentionsInPostsOfAuthorRow
+	for rows.Next() {
+		var i GetMentionsInPostsOfAuthorRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMentionsOfUser = `-- name: GetMentionsOfUser :many
+SELECT post_mentions.post_id, posts.user_id AS author_id FROM post_mentions
+JOIN posts ON posts.id = post_mentions.post_id
+WHERE post_mentions.user_id = $1
+AND posts.user_id <> $1
+ORDER BY posts.created_at
+`
+
+type GetMentionsOfUserRow struct {
+	PostID   uuid.UUID
+	AuthorID uuid.UUID
+}
+
+// Chirps by others that mention the user.
+func (q *Queries) GetMentionsOfUser(ctx context.Context, userID uuid.UUID) ([]GetMentionsOfUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMentionsOfUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMentionsOfUserRow
+	for rows.Next() {
+		var i GetMentionsOfUserRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPost = `-- name: GetPost :one
-SELECT id, created_at, updated_at, body, user_id FROM posts
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
 WHERE id = $1
 `
 
@@ -65,17 +271,36 @@ func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
 	)
 	return i, err
 }
 
 const getPosts = `-- name: GetPosts :many
-SELECT id, created_at, updated_at, body, user_id FROM posts
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
+WHERE (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = $1
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = $1
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = $1
+    ))
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetPosts(ctx context.Context) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPosts)
+func (q *Queries) GetPosts(ctx context.Context, viewerID uuid.UUID) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPosts, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +314,10 @@ func (q *Queries) GetPosts(ctx context.Context) ([]Post, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Visibility,
+			&i.PinnedAt,
+			&i.ExpiresAt,
+			&i.ImportKey,
 		); err != nil {
 			return nil, err
 		}
@@ -104,13 +333,33 @@ func (q *Queries) GetPosts(ctx context.Context) ([]Post, error) {
 }
 
 const getPostsOfAuthor = `-- name: GetPostsOfAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM posts
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
 WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = $2
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = $2
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = $2
+    ))
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetPostsOfAuthor(ctx context.Context, userID uuid.UUID) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsOfAuthor, userID)
+type GetPostsOfAuthorParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetPostsOfAuthor(ctx context.Context, arg GetPostsOfAuthorParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsOfAuthor, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +373,10 @@ func (q *Queries) GetPostsOfAuthor(ctx context.Context, userID uuid.UUID) ([]Pos
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Visibility,
+			&i.PinnedAt,
+			&i.ExpiresAt,
+			&i.ImportKey,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +390,188 @@ func (q *Queries) GetPostsOfAuthor(ctx context.Context, userID uuid.UUID) ([]Pos
 	}
 	return items, nil
 }
+
+const getUnexpiredPost = `-- name: GetUnexpiredPost :one
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
+WHERE id = $1
+AND (expires_at IS NULL OR expires_at > Now())
+`
+
+func (q *Queries) GetUnexpiredPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getUnexpiredPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const getVisiblePost = `-- name: GetVisiblePost :one
+SELECT id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key FROM posts
+WHERE id = $1
+AND (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = $2
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = $2
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = $2
+    ))
+)
+`
+
+type GetVisiblePostParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisiblePost(ctx context.Context, arg GetVisiblePostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getVisiblePost, arg.ID, arg.ViewerID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const importPost = `-- name: ImportPost :one
+INSERT INTO posts (id, created_at, updated_at, body, user_id, visibility, expires_at, import_key)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (user_id, import_key) DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
+`
+
+type ImportPostParams struct {
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	Visibility string
+	ExpiresAt  sql.NullTime
+	ImportKey  sql.NullString
+}
+
+// Chirps already imported under the same key are skipped, no row is
+// returned for them.
+func (q *Queries) ImportPost(ctx context.Context, arg ImportPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, importPost, arg.CreatedAt, arg.UpdatedAt, arg.Body, arg.UserID, arg.Visibility, arg.ExpiresAt, arg.ImportKey)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const pinPost = `-- name: PinPost :one
+UPDATE posts SET pinned_at = Now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
+`
+
+func (q *Queries) PinPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, pinPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const setPostExpiry = `-- name: SetPostExpiry :one
+UPDATE posts SET expires_at = Now() + make_interval(secs => $1::float8),
+updated_at = Now()
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
+`
+
+type SetPostExpiryParams struct {
+	ExpiresInSeconds float64
+	ID               uuid.UUID
+}
+
+func (q *Queries) SetPostExpiry(ctx context.Context, arg SetPostExpiryParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, setPostExpiry, arg.ExpiresInSeconds, arg.ID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
+
+const unpinPost = `-- name: UnpinPost :one
+UPDATE posts SET pinned_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, visibility, pinned_at, expires_at, import_key
+`
+
+func (q *Queries) UnpinPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, unpinPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Visibility,
+		&i.PinnedAt,
+		&i.ExpiresAt,
+		&i.ImportKey,
+	)
+	return i, err
+}
@@ -0,0 +1,86 @@
+// Package entitlements derives what a user is allowed to do from their
+// subscription, so handlers don't hard-code limits.
+package entitlements
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AbdKaan/chirpy/internal/database"
+)
+
+// Limits are the allowances of a single subscription tier.
+type Limits struct {
+	// MaxChirpLength is counted in characters as done by chirptext.Length.
+	MaxChirpLength  int
+	MaxPinnedChirps int
+	ChirpsPerHour   int
+	// ImportedChirpsPerDay caps the chirps imports add, they'd get around
+	// ChirpsPerHour otherwise.
+	ImportedChirpsPerDay int
+}
+
+// Tiers holds the limits of every subscription tier.
+type Tiers struct {
+	Free Limits
+	Red  Limits
+}
+
+// DefaultTiers are used for any limit that isn't configured.
+var DefaultTiers = Tiers{
+	Free: Limits{
+		MaxChirpLength:       140,
+		MaxPinnedChirps:      3,
+		ChirpsPerHour:        30,
+		ImportedChirpsPerDay: 1000,
+	},
+	Red: Limits{
+		MaxChirpLength:       280,
+		MaxPinnedChirps:      10,
+		ChirpsPerHour:        120,
+		ImportedChirpsPerDay: 10000,
+	},
+}
+
+// For returns the limits that apply to user.
+func (t Tiers) For(user database.User) Limits {
+	if user.IsChirpyRed {
+		return t.Red
+	}
+	return t.Free
+}
+
+// Actions that can be held back until a user has verified their email.
+const (
+	ActionChirp  = "chirp"
+	ActionFollow = "follow"
+)
+
+// Unverified is the set of actions users can't take before verifying their
+// email.
+type Unverified map[string]bool
+
+// DefaultUnverified keeps unverified users from chirping.
+var DefaultUnverified = Unverified{ActionChirp: true}
+
+// ParseUnverified reads a comma separated list of actions, like
+// "chirp,follow". An empty list restricts nothing.
+func ParseUnverified(list string) (Unverified, error) {
+	unverified := Unverified{}
+	for _, action := range strings.Split(list, ",") {
+		action = strings.TrimSpace(action)
+		switch action {
+		case "":
+		case ActionChirp, ActionFollow:
+			unverified[action] = true
+		default:
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+	}
+	return unverified, nil
+}
+
+// Allows reports whether user may take action.
+func (u Unverified) Allows(user database.User, action string) bool {
+	return user.EmailVerifiedAt.Valid || !u[action]
+}
@@ -0,0 +1,72 @@
+// Package loginguard decides how long a login has to wait after failed
+// password guesses, so passwords can't be brute forced.
+package loginguard
+
+import "time"
+
+// Policy is how failed logins are throttled. Failures are counted per
+// account and per IP address, each with its own policy.
+type Policy struct {
+	// Window is how long a failure counts against later logins.
+	Window time.Duration
+	// FreeAttempts failures are allowed before logins are slowed down.
+	FreeAttempts int64
+	// BaseDelay is the wait after the first failure past FreeAttempts. It
+	// doubles with every failure after that, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock logins out for LockoutDuration.
+	LockoutAfter    int64
+	LockoutDuration time.Duration
+}
+
+var DefaultAccountPolicy = Policy{
+	Window:          time.Hour,
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// DefaultIPPolicy is looser than the account policy since many users can
+// share an address.
+var DefaultIPPolicy = Policy{
+	Window:          time.Hour,
+	FreeAttempts:    10,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    50,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// Wait returns how much longer a login has to wait after failures within
+// the window, the last of which was sinceLast ago. locked is set when the
+// wait is a lockout rather than a backoff.
+func (p Policy) Wait(failures int64, sinceLast time.Duration) (wait time.Duration, locked bool) {
+	var delay time.Duration
+	switch {
+	case failures >= p.LockoutAfter:
+		delay = p.LockoutDuration
+		locked = true
+	case failures > p.FreeAttempts:
+		delay = p.MaxDelay
+		// stop doubling before it could overflow
+		if shift := failures - p.FreeAttempts - 1; shift < 32 {
+			delay = min(p.BaseDelay<<shift, p.MaxDelay)
+		}
+	default:
+		return 0, false
+	}
+
+	if sinceLast >= delay {
+		return 0, false
+	}
+	return delay - sinceLast, locked
+}
+
+// LocksOut reports whether the failure that brings the count to failures
+// is the one that starts a lockout.
+func (p Policy) LocksOut(failures int64) bool {
+	return failures == p.LockoutAfter
+}
@@ -0,0 +1,42 @@
+package loginguard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	p := Policy{
+		Window:          time.Hour,
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+	}
+
+	tests := []struct {
+		failures  int64
+		sinceLast time.Duration
+		wait      time.Duration
+		locked    bool
+	}{
+		{0, 0, 0, false},
+		{3, 0, 0, false},
+		{4, 0, time.Second, false},
+		{5, 0, 2 * time.Second, false},
+		{6, 500 * time.Millisecond, 3500 * time.Millisecond, false},
+		{6, 5 * time.Second, 0, false},
+		{9, 0, 10 * time.Second, false},
+		{10, time.Minute, 14 * time.Minute, true},
+		{10, 15 * time.Minute, 0, false},
+		{1000, 0, 15 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		wait, locked := p.Wait(tt.failures, tt.sinceLast)
+		if wait != tt.wait || locked != tt.locked {
+			t.Errorf("Wait(%d, %s) = %s, %v, want %s, %v", tt.failures, tt.sinceLast, wait, locked, tt.wait, tt.locked)
+		}
+	}
+}
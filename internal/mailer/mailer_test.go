@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatalf("loading templates: %v", err)
+	}
+
+	msg, err := templates.Render(TemplateVerifyEmail, map[string]string{
+		"Link": "https://chirpy.example/verify?token=a&b",
+	})
+	if err != nil {
+		t.Fatalf("rendering: %v", err)
+	}
+
+	if msg.Subject != "Verify your Chirpy email" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "https://chirpy.example/verify?token=a&b") {
+		t.Errorf("text is missing the link: %q", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, `href="https://chirpy.example/verify?token=a&amp;b"`) {
+		t.Errorf("html is missing the escaped link: %q", msg.HTML)
+	}
+
+	if _, err := templates.Render("no_such_template", nil); err == nil {
+		t.Errorf("rendering an unknown template should fail")
+	}
+}
+
+func TestMailers(t *testing.T) {
+	msg := Message{To: "user@example.com", Subject: "Hello", Text: "Hi there\n", HTML: "<p>Hi there</p>"}
+
+	memory := &MemoryMailer{}
+	if err := memory.Send(context.Background(), msg); err != nil {
+		t.Fatalf("sending to memory: %v", err)
+	}
+	if sent := memory.Messages(); len(sent) != 1 || sent[0] != msg {
+		t.Errorf("memory mailer kept %v", sent)
+	}
+
+	dir := t.TempDir()
+	file := FileMailer{Dir: dir, From: "Chirpy <no-reply@chirpy.local>"}
+	if err := file.Send(context.Background(), msg); err != nil {
+		t.Fatalf("sending to files: %v", err)
+	}
+	emls, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(emls) != 1 {
+		t.Fatalf("expected one .eml file, found %v", emls)
+	}
+	eml, _ := os.ReadFile(emls[0])
+	for _, want := range []string{"To: user@example.com\r\n", "Subject: Hello\r\n", "multipart/alternative", "text/html"} {
+		if !strings.Contains(string(eml), want) {
+			t.Errorf(".eml file is missing %q", want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	o := &Outbox{RetryDelay: time.Second, MaxRetryDelay: 10 * time.Second}
+
+	for attempts, want := range map[int32]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		50: 10 * time.Second,
+	} {
+		if got := o.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/database"
+)
+
+// Outbox is a Mailer that stores messages in the database and delivers
+// them through Transport in the background, retrying failed deliveries
+// with exponential backoff. Messages survive restarts and mail server
+// outages.
+type Outbox struct {
+	db        *database.Queries
+	transport Mailer
+
+	// BatchSize messages are delivered per run.
+	BatchSize int32
+	// Lease is how long a claimed message waits before another run may
+	// try it, should this one crash mid-delivery.
+	Lease time.Duration
+	// RetryDelay is the wait after the first failed delivery, it doubles
+	// after every failure up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// MaxAttempts failed deliveries give up on a message.
+	MaxAttempts int32
+}
+
+func NewOutbox(db *database.Queries, transport Mailer) *Outbox {
+	return &Outbox{
+		db:            db,
+		transport:     transport,
+		BatchSize:     20,
+		Lease:         5 * time.Minute,
+		RetryDelay:    30 * time.Second,
+		MaxRetryDelay: 6 * time.Hour,
+		MaxAttempts:   10,
+	}
+}
+
+// Send queues msg for delivery.
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	_, err := o.db.CreateOutboxMessage(ctx, database.CreateOutboxMessageParams{
+		ToAddress: msg.To,
+		Subject:   msg.Subject,
+		TextBody:  msg.Text,
+		HtmlBody:  msg.HTML,
+	})
+	return err
+}
+
+// Run delivers due messages every interval.
+func (o *Outbox) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := o.Deliver(context.Background())
+		if err != nil {
+			log.Printf("Couldn't deliver outbox: %s", err)
+		}
+	}
+}
+
+// Deliver sends one batch of due messages.
+func (o *Outbox) Deliver(ctx context.Context) error {
+	messages, err := o.db.ClaimOutboxMessages(ctx, database.ClaimOutboxMessagesParams{
+		LeaseSeconds: o.Lease.Seconds(),
+		BatchSize:    o.BatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		sendErr := o.transport.Send(ctx, Message{
+			To:      message.ToAddress,
+			Subject: message.Subject,
+			Text:    message.TextBody,
+			HTML:    message.HtmlBody,
+		})
+		if sendErr == nil {
+			err = o.db.MarkOutboxMessageSent(ctx, message.ID)
+		} else if message.Attempts >= o.MaxAttempts {
+			log.Printf("Giving up on mail %s to %s after %d attempts: %s", message.ID, message.ToAddress, message.Attempts, sendErr)
+			err = o.db.FailOutboxMessage(ctx, database.FailOutboxMessageParams{
+				ID:        message.ID,
+				LastError: sql.NullString{String: sendErr.Error(), Valid: true},
+			})
+		} else {
+			err = o.db.RetryOutboxMessage(ctx, database.RetryOutboxMessageParams{
+				ID:             message.ID,
+				LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+				RetryInSeconds: o.retryDelay(message.Attempts).Seconds(),
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryDelay is the wait after the attempts-th failed delivery.
+func (o *Outbox) retryDelay(attempts int32) time.Duration {
+	delay := o.RetryDelay
+	for i := int32(1); i < attempts && delay < o.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxRetryDelay)
+}
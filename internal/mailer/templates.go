@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Names of the bundled message templates.
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplatePasswordReset   = "password_reset"
+	TemplateAccountLocked   = "account_locked"
+	TemplateAccountDeletion = "account_deletion"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Templates render messages from the bundled templates. Every message type
+// has a text template, name.txt, defining the "subject" and "text" blocks,
+// and may have an HTML template, name.html.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+
+	files, err := fs.Glob(templateFiles, "templates/*")
+	if err != nil {
+		return nil, err
+	}
+
+	// every file is parsed on its own since they all define the same blocks
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+		switch path.Ext(file) {
+		case ".txt":
+			t.text[name], err = texttemplate.ParseFS(templateFiles, file)
+		case ".html":
+			t.html[name], err = htmltemplate.ParseFS(templateFiles, file)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Render builds the message name from data, To is left for the caller.
+func (t *Templates) Render(name string, data any) (Message, error) {
+	textTemplate, ok := t.text[name]
+	if !ok {
+		return Message{}, fmt.Errorf("no mail template %q", name)
+	}
+
+	var subject, text bytes.Buffer
+	err := textTemplate.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	err = textTemplate.ExecuteTemplate(&text, "text", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+
+	if htmlTemplate, ok := t.html[name]; ok {
+		var html bytes.Buffer
+		err = htmlTemplate.Execute(&html, data)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.HTML = html.String()
+	}
+
+	return msg, nil
+}
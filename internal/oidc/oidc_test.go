@@ -0,0 +1,82 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AbdKaan/chirpy/internal/oidc/oidctest"
+)
+
+func TestCodeFlow(t *testing.T) {
+	mock, err := oidctest.NewProvider("chirpy", "secret")
+	if err != nil {
+		t.Fatalf("starting mock provider: %v", err)
+	}
+	defer mock.Close()
+	mock.SetIdentity(oidctest.Identity{Subject: "1234", Email: "sso@example.com", EmailVerified: true})
+
+	ctx := context.Background()
+	redirectURI := "http://localhost:8080/api/oidc/mock/callback"
+	provider := NewProvider("mock", Config{
+		Issuer:       mock.Issuer(),
+		ClientID:     "chirpy",
+		ClientSecret: "secret",
+	})
+
+	// signIn runs the flow up to the ID token, exchanging the code with
+	// verifier when it's set
+	signIn := func(verifier string) (idToken, nonce string, err error) {
+		t.Helper()
+		state, _ := RandomToken()
+		nonce, _ = RandomToken()
+		realVerifier, _ := RandomToken()
+
+		authURL, err := provider.AuthCodeURL(ctx, state, nonce, realVerifier, redirectURI)
+		if err != nil {
+			t.Fatalf("building authorization URL: %v", err)
+		}
+		callback, err := mock.Authorize(authURL)
+		if err != nil {
+			t.Fatalf("authorizing: %v", err)
+		}
+		if callback.Query().Get("state") != state {
+			t.Fatalf("state = %q, want %q", callback.Query().Get("state"), state)
+		}
+
+		if verifier == "" {
+			verifier = realVerifier
+		}
+		idToken, err = provider.Exchange(ctx, callback.Query().Get("code"), verifier, redirectURI)
+		return idToken, nonce, err
+	}
+
+	idToken, nonce, err := signIn("")
+	if err != nil {
+		t.Fatalf("exchanging code: %v", err)
+	}
+	identity, err := provider.VerifyIDToken(ctx, idToken, nonce)
+	if err != nil {
+		t.Fatalf("verifying ID token: %v", err)
+	}
+	want := Identity{Issuer: mock.Issuer(), Subject: "1234", Email: "sso@example.com", EmailVerified: true}
+	if identity != want {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+
+	_, err = provider.VerifyIDToken(ctx, idToken, "another nonce")
+	if !errors.Is(err, ErrIDTokenInvalid) {
+		t.Errorf("expected ErrIDTokenInvalid for a wrong nonce, got: %v", err)
+	}
+
+	other := NewProvider("other", Config{Issuer: mock.Issuer(), ClientID: "someone-else"})
+	_, err = other.VerifyIDToken(ctx, idToken, nonce)
+	if !errors.Is(err, ErrIDTokenInvalid) {
+		t.Errorf("expected ErrIDTokenInvalid for another audience, got: %v", err)
+	}
+
+	_, _, err = signIn("not the verifier")
+	if !errors.Is(err, ErrExchange) {
+		t.Errorf("expected ErrExchange for the wrong PKCE verifier, got: %v", err)
+	}
+}
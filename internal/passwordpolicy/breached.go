@@ -0,0 +1,134 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// PrefixLength is how many hex characters of a SHA-1 hash are given to a
+// RangeSource. Every range holds hundreds of hashes, so the source can't
+// tell which password is being checked.
+const PrefixLength = 5
+
+// RangeSource returns the upper case SHA-1 hash suffixes of breached
+// passwords whose hash starts with prefix, like the Pwned Passwords range
+// API.
+type RangeSource interface {
+	Range(prefix string) ([]string, error)
+}
+
+// IsBreached reports whether password is in source, only revealing the
+// prefix of its hash.
+func IsBreached(source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(hash[:PrefixLength])
+	if err != nil {
+		return false, err
+	}
+
+	for _, suffix := range suffixes {
+		if suffix == hash[PrefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HashFile is a RangeSource reading a local file of "HASH:COUNT" lines
+// sorted by hash, the format of the Pwned Passwords SHA-1 download. The
+// file is binary searched, not loaded into memory.
+type HashFile struct {
+	file *os.File
+	size int64
+}
+
+func OpenHashFile(path string) (*HashFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &HashFile{file: file, size: info.Size()}, nil
+}
+
+func (f *HashFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *HashFile) Range(prefix string) ([]string, error) {
+	prefix = strings.ToUpper(prefix)
+
+	// find the first line at or after an offset whose hash isn't before
+	// the prefix, lines only grow as the offset does
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, _, err := f.lineAt(mid)
+		if err != nil {
+			return nil, err
+		}
+		if line != "" && line < prefix {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	_, start, err := f.lineAt(lo)
+	if err != nil {
+		return nil, err
+	}
+
+	var suffixes []string
+	scanner := bufio.NewScanner(io.NewSectionReader(f.file, start, f.size-start))
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		hash = strings.ToUpper(hash)
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		suffixes = append(suffixes, hash[len(prefix):])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return suffixes, nil
+}
+
+// lineAt returns the first line starting at or after off, upper cased, and
+// where it starts. The line is empty at the end of the file.
+func (f *HashFile) lineAt(off int64) (string, int64, error) {
+	start := off
+	if off > 0 {
+		// unless off is right after a newline it's in the middle of a line
+		r := bufio.NewReader(io.NewSectionReader(f.file, off-1, f.size-off+1))
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return "", f.size, nil
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		start = off - 1 + int64(len(skipped))
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f.file, start, f.size-start))
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	return strings.ToUpper(strings.TrimSpace(line)), start, nil
+}
@@ -0,0 +1,124 @@
+// Package passwordpolicy decides which passwords users may choose.
+package passwordpolicy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of the rules a password can break.
+const (
+	CodeInvalidUTF8 = "invalid_utf8"
+	CodeTooShort    = "too_short"
+	CodeTooLong     = "too_long"
+	CodeCommon      = "common"
+	CodeBreached    = "breached"
+)
+
+// Violation is a rule a password breaks, Code is for programs and Message
+// for people.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every rule a password breaks.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	codes := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		codes[i] = v.Code
+	}
+	return "password breaks the policy: " + strings.Join(codes, ", ")
+}
+
+// Policy is what a password has to satisfy.
+type Policy struct {
+	// MinLength is counted in characters.
+	MinLength int
+	// MaxLength is counted in bytes, since bcrypt ignores anything past
+	// 72 bytes.
+	MaxLength int
+	// Banned are lower case passwords that are too common to allow.
+	Banned map[string]bool
+	// Breached is checked for passwords known from breaches, nil skips the
+	// check.
+	Breached RangeSource
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// CommonPasswords is the bundled list of passwords too common to allow.
+func CommonPasswords() map[string]bool {
+	banned := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			banned[strings.ToLower(password)] = true
+		}
+	}
+	return banned
+}
+
+// DefaultPolicy is used for any setting that isn't configured.
+var DefaultPolicy = Policy{
+	MinLength: 8,
+	MaxLength: 72,
+	Banned:    CommonPasswords(),
+}
+
+// Validate returns a *ValidationError listing every rule password breaks,
+// or another error if the breached password check couldn't be done.
+func (p Policy) Validate(password string) error {
+	if !utf8.ValidString(password) {
+		return &ValidationError{Violations: []Violation{{
+			Code:    CodeInvalidUTF8,
+			Message: "Password is not valid UTF-8",
+		}}}
+	}
+
+	var violations []Violation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.MaxLength),
+		})
+	}
+	if p.Banned[strings.ToLower(password)] {
+		violations = append(violations, Violation{
+			Code:    CodeCommon,
+			Message: "Password is too common",
+		})
+	}
+
+	if p.Breached != nil {
+		breached, err := IsBreached(p.Breached, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Code:    CodeBreached,
+				Message: "Password has appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package passwordpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	p := Policy{MinLength: 8, MaxLength: 72, Banned: CommonPasswords()}
+
+	tests := []struct {
+		password string
+		codes    []string
+	}{
+		{"", []string{CodeTooShort}},
+		{"short", []string{CodeTooShort}},
+		{"Password", []string{CodeCommon}},
+		{"correct horse battery staple", nil},
+		{"çççççççç", nil},
+		{strings.Repeat("a", 73), []string{CodeTooLong}},
+		{"\xff\xfe\xfd\xfc\xfb\xfa\xf9\xf8", []string{CodeInvalidUTF8}},
+	}
+
+	for _, tt := range tests {
+		err := p.Validate(tt.password)
+		var codes []string
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			for _, v := range invalid.Violations {
+				codes = append(codes, v.Code)
+			}
+		} else if err != nil {
+			t.Fatalf("Validate(%q): %v", tt.password, err)
+		}
+
+		if strings.Join(codes, ",") != strings.Join(tt.codes, ",") {
+			t.Errorf("Validate(%q) broke %v, want %v", tt.password, codes, tt.codes)
+		}
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	breached := []string{"hunter2", "correct horse battery staple", "letmein"}
+	var lines []string
+	for _, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	// filler around the real hashes so the search has to skip lines
+	for _, filler := range []string{"00000", "7FFFF", "FFFFF"} {
+		lines = append(lines, filler+strings.Repeat("0", 35)+":1")
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600)
+	if err != nil {
+		t.Fatalf("writing hash file: %v", err)
+	}
+
+	file, err := OpenHashFile(path)
+	if err != nil {
+		t.Fatalf("opening hash file: %v", err)
+	}
+	defer file.Close()
+
+	for _, password := range breached {
+		ok, err := IsBreached(file, password)
+		if err != nil || !ok {
+			t.Errorf("IsBreached(%q) = %v, %v, want true", password, ok, err)
+		}
+	}
+
+	ok, err := IsBreached(file, "not breached at all")
+	if err != nil || ok {
+		t.Errorf("IsBreached of a safe password = %v, %v, want false", ok, err)
+	}
+}
@@ -0,0 +1,113 @@
+package userarchive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Item is a chirp read for import. Number is its 1-based position in the
+// upload, Err says why it couldn't be read when it couldn't.
+type Item struct {
+	Number int
+	Chirp  Chirp
+	Err    error
+}
+
+var (
+	ErrMissingBody      = errors.New("chirp has no body")
+	ErrMissingCreatedAt = errors.New("chirp has no created_at")
+)
+
+// Key identifies the chirp across imports: its ID when it came from an
+// export, otherwise a hash of when it was written and what it says.
+func (c Chirp) Key() string {
+	if c.ID != uuid.Nil {
+		return "chirp:" + c.ID.String()
+	}
+	sum := sha256.Sum256([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "\n" + c.Body))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// UTC returns the chirp with its times in UTC. The database stores them
+// without an offset, so any other zone would shift them by its offset.
+func (c Chirp) UTC() Chirp {
+	c.CreatedAt = c.CreatedAt.UTC()
+	c.UpdatedAt = c.UpdatedAt.UTC()
+	if c.PinnedAt != nil {
+		pinnedAt := c.PinnedAt.UTC()
+		c.PinnedAt = &pinnedAt
+	}
+	if c.ExpiresAt != nil {
+		expiresAt := c.ExpiresAt.UTC()
+		c.ExpiresAt = &expiresAt
+	}
+	return c
+}
+
+// ReadChirps reads the chirps of an upload, which is either an archive
+// written by Write or JSON Lines with one chirp per line. An error is only
+// returned when the upload as a whole can't be read, a chirp that can't be
+// read is returned as an Item with Err set.
+func ReadChirps(data []byte) ([]Item, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		a, err := Read(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]Item, 0, len(a.Chirps))
+		for i, chirp := range a.Chirps {
+			items = append(items, Item{Number: i + 1, Chirp: chirp, Err: checkChirp(chirp)})
+		}
+		return items, nil
+	}
+
+	return readJSONLines(bytes.NewReader(data))
+}
+
+func readJSONLines(r io.Reader) ([]Item, error) {
+	items := []Item{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		chirp := Chirp{}
+		err := json.Unmarshal(text, &chirp)
+		if err != nil {
+			items = append(items, Item{Number: line, Err: fmt.Errorf("parsing line: %v", err)})
+			continue
+		}
+		items = append(items, Item{Number: line, Chirp: chirp, Err: checkChirp(chirp)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading line %d: %w", line+1, err)
+	}
+
+	return items, nil
+}
+
+func checkChirp(chirp Chirp) error {
+	if chirp.Body == "" {
+		return ErrMissingBody
+	}
+	if chirp.CreatedAt.IsZero() {
+		return ErrMissingCreatedAt
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Package userarchive reads and writes the ZIP archives users export their
+// data as, and can import chirps back from.
+package userarchive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Format and Version identify an archive in its manifest. Version goes up
+// when a change would keep older readers from understanding it.
+const (
+	Format  = "chirpy-export"
+	Version = 1
+)
+
+var ErrNotAnArchive = errors.New("not a Chirpy export archive")
+
+// MaxEntrySize is the most a file in an archive may inflate to. The size
+// of an upload says little about what it holds once uncompressed.
+const MaxEntrySize = 64 << 20
+
+type Manifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	UserID     uuid.UUID `json:"user_id"`
+}
+
+type Profile struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Email               string     `json:"email"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
+	IsChirpyRed         bool       `json:"is_chirpy_red"`
+	TwoFactorEnabledAt  *time.Time `json:"two_factor_enabled_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+type Chirp struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	Visibility string     `json:"visibility"`
+	PinnedAt   *time.Time `json:"pinned_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	// Mentions are the users the chirp mentions.
+	Mentions []uuid.UUID `json:"mentions,omitempty"`
+}
+
+// Mention is a chirp by another user that mentions this one.
+type Mention struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+// Follow is one side of a follow, UserID is the other user.
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+}
+
+// AccessToken describes a personal access token, the token itself is
+// never stored so it can't be exported.
+type AccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Identity is an account at an external identity provider the user can
+// sign in with.
+type Identity struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Provider    string     `json:"provider"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OAuthGrant is access the user gave an OAuth client, its refresh token
+// is only stored hashed so it isn't exported.
+type OAuthGrant struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ClientID   uuid.UUID  `json:"client_id"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// OAuthClient is an app the user registered, without its secret.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+}
+
+// Import is an import of chirps the user ran, Errors say which of them
+// weren't imported and why.
+type Import struct {
+	ID          uuid.UUID     `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Status      string        `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	Total       int           `json:"total"`
+	Imported    int           `json:"imported"`
+	Skipped     int           `json:"skipped"`
+	Failed      int           `json:"failed"`
+	CompletedAt *time.Time    `json:"completed_at"`
+	Errors      []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is why the Item'th chirp of an import failed.
+type ImportError struct {
+	Item    int    `json:"item"`
+	Message string `json:"message"`
+}
+
+// Archive is everything stored about a user.
+type Archive struct {
+	Manifest     Manifest
+	Profile      Profile
+	Chirps       []Chirp
+	Mentions     []Mention
+	Following    []Follow
+	Followers    []Follow
+	Sessions     []Session
+	AccessTokens []AccessToken
+	Identities   []Identity
+	OAuthGrants  []OAuthGrant
+	OAuthClients []OAuthClient
+	Imports      []Import
+}
+
+// files maps every file in an archive to the part of Archive it holds.
+func (a *Archive) files() []struct {
+	name string
+	v    any
+} {
+	return []struct {
+		name string
+		v    any
+	}{
+		{"manifest.json", &a.Manifest},
+		{"profile.json", &a.Profile},
+		{"chirps.json", &a.Chirps},
+		{"mentions.json", &a.Mentions},
+		{"following.json", &a.Following},
+		{"followers.json", &a.Followers},
+		{"sessions.json", &a.Sessions},
+		{"access_tokens.json", &a.AccessTokens},
+		{"identities.json", &a.Identities},
+		{"oauth_grants.json", &a.OAuthGrants},
+		{"oauth_clients.json", &a.OAuthClients},
+		{"imports.json", &a.Imports},
+	}
+}
+
+// Write writes a as a ZIP archive of JSON files, one per kind of data.
+func Write(w io.Writer, a Archive) error {
+	a.Manifest.Format = Format
+	a.Manifest.Version = Version
+
+	zw := zip.NewWriter(w)
+	for _, file := range a.files() {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: a.Manifest.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.v)
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// Read reads an archive written by Write. Files missing from the archive
+// are left empty, so only the manifest is required.
+func Read(r io.ReaderAt, size int64) (Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Archive{}, fmt.Errorf("%w: %v", ErrNotAnArchive, err)
+	}
+
+	a := Archive{}
+	for _, file := range a.files() {
+		f, err := zr.Open(file.name)
+		if errors.Is(err, fs.ErrNotExist) && file.name != "manifest.json" {
+			continue
+		}
+		if err != nil {
+			return Archive{}, fmt.Errorf("%w: opening %s: %v", ErrNotAnArchive, file.name, err)
+		}
+
+		info, err := f.Stat()
+		if err != nil || info.Size() > MaxEntrySize {
+			f.Close()
+			return Archive{}, fmt.Errorf("%w: %s is too large", ErrNotAnArchive, file.name)
+		}
+
+		// the header's size can lie, the reader can't
+		err = json.NewDecoder(io.LimitReader(f, MaxEntrySize)).Decode(file.v)
+		f.Close()
+		if err != nil {
+			return Archive{}, fmt.Errorf("%w: reading %s: %v", ErrNotAnArchive, file.name, err)
+		}
+	}
+
+	if a.Manifest.Format != Format {
+		return Archive{}, ErrNotAnArchive
+	}
+	if a.Manifest.Version > Version {
+		return Archive{}, fmt.Errorf("archive is version %d, only up to %d is supported", a.Manifest.Version, Version)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,159 @@
+package userarchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteRead(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	userID := uuid.New()
+
+	original := Archive{
+		Manifest: Manifest{ExportedAt: now, UserID: userID},
+		Profile:  Profile{ID: userID, CreatedAt: now, UpdatedAt: now, Email: "user@example.com"},
+		Chirps: []Chirp{
+			{ID: uuid.New(), CreatedAt: now.Add(-time.Hour), UpdatedAt: now, Body: "hello", Visibility: "public"},
+		},
+		Following: []Follow{{UserID: uuid.New(), CreatedAt: now}},
+		Imports: []Import{
+			{ID: uuid.New(), CreatedAt: now, Status: "done", Total: 2, Imported: 1, Failed: 1, Errors: []ImportError{{Item: 2, Message: "body is too long"}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, original); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+
+	read, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+
+	if read.Manifest.Format != Format || read.Manifest.Version != Version {
+		t.Errorf("manifest = %+v", read.Manifest)
+	}
+	if read.Profile.Email != original.Profile.Email {
+		t.Errorf("profile email = %q, want %q", read.Profile.Email, original.Profile.Email)
+	}
+	if len(read.Chirps) != 1 || read.Chirps[0].Body != "hello" || !read.Chirps[0].CreatedAt.Equal(original.Chirps[0].CreatedAt) {
+		t.Errorf("chirps = %+v", read.Chirps)
+	}
+	if len(read.Following) != 1 || read.Following[0].UserID != original.Following[0].UserID {
+		t.Errorf("following = %+v", read.Following)
+	}
+	if len(read.Imports) != 1 || len(read.Imports[0].Errors) != 1 || read.Imports[0].Errors[0].Item != 2 {
+		t.Errorf("imports = %+v", read.Imports)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not a zip")), 9); !errors.Is(err, ErrNotAnArchive) {
+		t.Errorf("expected ErrNotAnArchive, got: %v", err)
+	}
+}
+
+func TestReadChirps(t *testing.T) {
+	lines := `{"body": "first", "created_at": "2024-01-02T03:04:05Z"}
+
+not json
+{"body": "", "created_at": "2024-01-02T03:04:05Z"}
+{"body": "no time"}
+`
+	items, err := ReadChirps([]byte(lines))
+	if err != nil {
+		t.Fatalf("reading lines: %v", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("got %d items, want 4: %+v", len(items), items)
+	}
+	if items[0].Err != nil || items[0].Number != 1 || items[0].Chirp.Body != "first" {
+		t.Errorf("first item = %+v", items[0])
+	}
+	if items[1].Err == nil || items[1].Number != 3 {
+		t.Errorf("expected a parse error on line 3, got %+v", items[1])
+	}
+	if !errors.Is(items[2].Err, ErrMissingBody) {
+		t.Errorf("expected ErrMissingBody, got %v", items[2].Err)
+	}
+	if !errors.Is(items[3].Err, ErrMissingCreatedAt) {
+		t.Errorf("expected ErrMissingCreatedAt, got %v", items[3].Err)
+	}
+
+	// the key of a chirp without an ID only depends on its content
+	again, _ := ReadChirps([]byte(lines))
+	if items[0].Chirp.Key() != again[0].Chirp.Key() {
+		t.Errorf("keys differ between reads")
+	}
+
+	var buf bytes.Buffer
+	archive := Archive{Chirps: []Chirp{{ID: uuid.New(), CreatedAt: time.Now(), Body: "exported"}}}
+	if err := Write(&buf, archive); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+	items, err = ReadChirps(buf.Bytes())
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	if len(items) != 1 || items[0].Err != nil || items[0].Chirp.Key() != "chirp:"+archive.Chirps[0].ID.String() {
+		t.Errorf("archive items = %+v", items)
+	}
+}
+
+func TestChirpUTC(t *testing.T) {
+	line := `{"body": "offset", "created_at": "2024-01-02T03:04:05+02:00", "updated_at": "2024-01-02T04:04:05+02:00", "expires_at": "2099-01-01T00:30:00-05:00"}`
+	items, err := ReadChirps([]byte(line))
+	if err != nil || len(items) != 1 || items[0].Err != nil {
+		t.Fatalf("reading line: %v %+v", err, items)
+	}
+
+	chirp := items[0].Chirp.UTC()
+	want := time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)
+	if chirp.CreatedAt != want {
+		t.Errorf("created_at = %v, want %v", chirp.CreatedAt, want)
+	}
+	if chirp.UpdatedAt != want.Add(time.Hour) {
+		t.Errorf("updated_at = %v, want %v", chirp.UpdatedAt, want.Add(time.Hour))
+	}
+	if chirp.ExpiresAt == nil || *chirp.ExpiresAt != time.Date(2099, 1, 1, 5, 30, 0, 0, time.UTC) {
+		t.Errorf("expires_at = %v", chirp.ExpiresAt)
+	}
+
+	// the key is the same whichever zone the time was written in
+	if chirp.Key() != items[0].Chirp.Key() {
+		t.Errorf("key changed with the zone")
+	}
+}
+
+func TestReadRejectsOversizedEntries(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest, _ := zw.Create("manifest.json")
+	manifest.Write([]byte(`{"format": "` + Format + `", "version": 1}`))
+
+	// whitespace compresses to almost nothing, the upload stays tiny while
+	// the entry inflates past the limit
+	chirps, _ := zw.Create("chirps.json")
+	chirps.Write([]byte("["))
+	padding := []byte(strings.Repeat(" ", 1<<20))
+	for written := 0; written <= MaxEntrySize; written += len(padding) {
+		chirps.Write(padding)
+	}
+	chirps.Write([]byte("]"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+	if buf.Len() > 1<<20 {
+		t.Fatalf("archive is %d bytes, expected it to compress well", buf.Len())
+	}
+
+	_, err := ReadChirps(buf.Bytes())
+	if !errors.Is(err, ErrNotAnArchive) || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected ErrNotAnArchive for a too large entry, got: %v", err)
+	}
+}
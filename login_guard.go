@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/mailer"
+)
+
+// lockoutNotifier is told when failed logins lock a user's account, so they
+// can be warned someone is guessing their password.
+type lockoutNotifier func(ctx context.Context, user database.User, ip string, until time.Time)
+
+// mailLockout warns the user their account was locked.
+func (cfg *apiConfig) mailLockout(ctx context.Context, user database.User, ip string, until time.Time) {
+	err := cfg.sendMail(ctx, user.Email, mailer.TemplateAccountLocked, map[string]any{
+		"IP":    ip,
+		"Until": until.UTC(),
+	})
+	if err != nil {
+		log.Printf("Couldn't mail lockout of %s: %s", user.ID, err)
+	}
+}
+
+// loginWait returns how long logins to email from ip have to wait because
+// of earlier failures. Failures are throttled per account and per IP
+// address, the longer wait wins.
+func (cfg *apiConfig) loginWait(ctx context.Context, email, ip string) (time.Duration, error) {
+	account, err := cfg.db.GetLoginFailuresOfEmail(ctx, database.GetLoginFailuresOfEmailParams{
+		Email:         strings.ToLower(email),
+		WindowSeconds: cfg.accountLoginPolicy.Window.Seconds(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	address, err := cfg.db.GetLoginFailuresOfIP(ctx, database.GetLoginFailuresOfIPParams{
+		IpAddress:     ip,
+		WindowSeconds: cfg.ipLoginPolicy.Window.Seconds(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	accountWait, _ := cfg.accountLoginPolicy.Wait(account.Failures, secondsToDuration(account.SecondsSinceLast))
+	addressWait, _ := cfg.ipLoginPolicy.Wait(address.Failures, secondsToDuration(address.SecondsSinceLast))
+	return max(accountWait, addressWait), nil
+}
+
+// respondWithLoginWait turns away a login that has to wait, telling the
+// client when to try again.
+func respondWithLoginWait(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed logins, try again later", nil)
+}
+
+// recordLoginFailure counts a failed login to email from ip. user is nil
+// when no account has that email, otherwise its owner is notified when this
+// failure locks the account.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email, ip string, user *database.User) error {
+	email = strings.ToLower(email)
+	err := cfg.db.CreateLoginFailure(ctx, database.CreateLoginFailureParams{
+		Email:     email,
+		IpAddress: ip,
+	})
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	account, err := cfg.db.GetLoginFailuresOfEmail(ctx, database.GetLoginFailuresOfEmailParams{
+		Email:         email,
+		WindowSeconds: cfg.accountLoginPolicy.Window.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.accountLoginPolicy.LocksOut(account.Failures) {
+		cfg.onLockout(ctx, *user, ip, time.Now().Add(cfg.accountLoginPolicy.LockoutDuration))
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures of an account after a successful
+// login. Failures of the IP address are kept, logging into an account of
+// your own shouldn't buy more guesses at someone else's.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, email string) error {
+	return cfg.db.DeleteLoginFailuresOfEmail(ctx, strings.ToLower(email))
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
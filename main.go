@@ -1,41 +1,141 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/AbdKaan/chirpy/internal/auth"
 	"github.com/AbdKaan/chirpy/internal/database"
+	"github.com/AbdKaan/chirpy/internal/entitlements"
+	"github.com/AbdKaan/chirpy/internal/loginguard"
+	"github.com/AbdKaan/chirpy/internal/mailer"
+	"github.com/AbdKaan/chirpy/internal/oidc"
+	"github.com/AbdKaan/chirpy/internal/passwordpolicy"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	secret         string
-	polkaKey       string
+	fileserverHits      atomic.Int32
+	dbConn              *sql.DB
+	db                  *database.Queries
+	platform            string
+	jwtKeys             *auth.KeySet
+	polkaKey            string
+	refreshTokenKey     string
+	tokenVersions       *auth.TokenVersionCache
+	totpKey             []byte
+	passwords           *auth.PasswordHasher
+	passwordPolicy      passwordpolicy.Policy
+	mailer              mailer.Mailer
+	mailTemplates       *mailer.Templates
+	baseURL             string
+	unverified          entitlements.Unverified
+	deletionGracePeriod time.Duration
+	accountLoginPolicy  loginguard.Policy
+	ipLoginPolicy       loginguard.Policy
+	onLockout           lockoutNotifier
+	tiers               entitlements.Tiers
+	oidcProviders       map[string]*oidc.Provider
+	passwordResets      chan passwordResetRequest
 }
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
 }
 
 type Post struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	User_ID   string    `json:"user_id"`
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	User_ID    string     `json:"user_id"`
+	Visibility string     `json:"visibility"`
+	PinnedAt   *time.Time `json:"pinned_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+type ExportJob struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+type ImportJob struct {
+	ID          uuid.UUID         `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Status      string            `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Total       int               `json:"total"`
+	Imported    int               `json:"imported"`
+	Skipped     int               `json:"skipped"`
+	Failed      int               `json:"failed"`
+	CompletedAt *time.Time        `json:"completed_at"`
+	Errors      []ImportItemError `json:"errors,omitempty"`
+}
+
+// ImportItemError says why a chirp wasn't imported, Item is its position
+// in the upload.
+type ImportItemError struct {
+	Item    int    `json:"item"`
+	Message string `json:"message"`
+}
+
+// UserIdentity is an account at an external identity provider the user
+// can sign in with.
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OAuthClient is a third-party app users can grant access to. Public
+// clients have no secret.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
 }
 
 func main() {
@@ -59,15 +159,132 @@ func main() {
 
 	secret := os.Getenv("SECRET")
 
+	jwtKeys, err := loadJWTKeys(secret)
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %s", err)
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		jwtKeys.Policy.Audience = audience
+	}
+
 	polkaKey := os.Getenv("POLKA_KEY")
 
+	refreshTokenKey := os.Getenv("REFRESH_TOKEN_KEY")
+	if refreshTokenKey == "" {
+		refreshTokenKey = secret
+	}
+
+	totpKeySecret := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if totpKeySecret == "" {
+		totpKeySecret = secret
+	}
+	totpKey := auth.EncryptionKey(totpKeySecret)
+
+	passwordAlgorithm := os.Getenv("PASSWORD_HASH_ALGORITHM")
+	if passwordAlgorithm == "" {
+		passwordAlgorithm = auth.AlgorithmArgon2id
+	}
+	argon2Params := auth.DefaultArgon2Params
+	argon2Params.Memory = uint32(envInt("ARGON2_MEMORY_KIB", int(argon2Params.Memory)))
+	argon2Params.Iterations = uint32(envInt("ARGON2_ITERATIONS", int(argon2Params.Iterations)))
+	argon2Params.Parallelism = uint8(envInt("ARGON2_PARALLELISM", int(argon2Params.Parallelism)))
+	passwords, err := auth.NewPasswordHasher(passwordAlgorithm, argon2Params, envInt("BCRYPT_COST", 10))
+	if err != nil {
+		log.Fatalf("Error configuring password hashing: %s", err)
+	}
+
+	passwordPolicy := passwordpolicy.DefaultPolicy
+	passwordPolicy.MinLength = envInt("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+	passwordPolicy.MaxLength = envInt("PASSWORD_MAX_LENGTH", passwordPolicy.MaxLength)
+	if passwordAlgorithm == auth.AlgorithmBcrypt && (passwordPolicy.MaxLength <= 0 || passwordPolicy.MaxLength > 72) {
+		log.Fatal("PASSWORD_MAX_LENGTH can't be over 72 bytes with bcrypt")
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := passwordpolicy.OpenHashFile(path)
+		if err != nil {
+			log.Fatalf("Error opening breached passwords file: %s", err)
+		}
+		passwordPolicy.Breached = breached
+	}
+
+	tokenVersions := auth.NewTokenVersionCache(func(ctx context.Context, userID uuid.UUID) (int32, error) {
+		user, err := dbQueries.GetUser(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		return user.TokenVersion, nil
+	}, 30*time.Second)
+
+	defaults := entitlements.DefaultTiers
+	tiers := entitlements.Tiers{
+		Free: entitlements.Limits{
+			MaxChirpLength:       envInt("CHIRP_LENGTH_LIMIT", defaults.Free.MaxChirpLength),
+			MaxPinnedChirps:      envInt("PINNED_CHIRPS_LIMIT", defaults.Free.MaxPinnedChirps),
+			ChirpsPerHour:        envInt("CHIRPS_PER_HOUR", defaults.Free.ChirpsPerHour),
+			ImportedChirpsPerDay: envInt("IMPORTED_CHIRPS_PER_DAY", defaults.Free.ImportedChirpsPerDay),
+		},
+		Red: entitlements.Limits{
+			MaxChirpLength:       envInt("CHIRP_LENGTH_LIMIT_RED", defaults.Red.MaxChirpLength),
+			MaxPinnedChirps:      envInt("PINNED_CHIRPS_LIMIT_RED", defaults.Red.MaxPinnedChirps),
+			ChirpsPerHour:        envInt("CHIRPS_PER_HOUR_RED", defaults.Red.ChirpsPerHour),
+			ImportedChirpsPerDay: envInt("IMPORTED_CHIRPS_PER_DAY_RED", defaults.Red.ImportedChirpsPerDay),
+		},
+	}
+
+	unverified := entitlements.DefaultUnverified
+	if list, ok := os.LookupEnv("UNVERIFIED_RESTRICTIONS"); ok {
+		unverified, err = entitlements.ParseUnverified(list)
+		if err != nil {
+			log.Fatalf("UNVERIFIED_RESTRICTIONS is invalid: %s", err)
+		}
+	}
+
+	transport, err := loadMailer()
+	if err != nil {
+		log.Fatalf("Error configuring mailer: %s", err)
+	}
+	outbox := mailer.NewOutbox(dbQueries, transport)
+
+	mailTemplates, err := mailer.LoadTemplates()
+	if err != nil {
+		log.Fatalf("Error loading mail templates: %s", err)
+	}
+
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		log.Fatalf("Error configuring identity providers: %s", err)
+	}
+
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		secret:         secret,
-		polkaKey:       polkaKey,
+		fileserverHits:      atomic.Int32{},
+		dbConn:              dbConn,
+		db:                  dbQueries,
+		platform:            platform,
+		jwtKeys:             jwtKeys,
+		polkaKey:            polkaKey,
+		refreshTokenKey:     refreshTokenKey,
+		tokenVersions:       tokenVersions,
+		totpKey:             totpKey,
+		passwords:           passwords,
+		passwordPolicy:      passwordPolicy,
+		mailer:              outbox,
+		mailTemplates:       mailTemplates,
+		baseURL:             baseURL,
+		unverified:          unverified,
+		deletionGracePeriod: envDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+		accountLoginPolicy:  loginguard.DefaultAccountPolicy,
+		ipLoginPolicy:       loginguard.DefaultIPPolicy,
+		tiers:               tiers,
+		oidcProviders:       oidcProviders,
+		passwordResets:      make(chan passwordResetRequest, passwordResetQueueSize),
 	}
+	apiCfg.onLockout = apiCfg.mailLockout
 
 	handler := http.NewServeMux()
 
@@ -75,25 +292,87 @@ func main() {
 	handler.Handle("/app/", fsHandler)
 
 	handler.HandleFunc("GET /api/healthz", handlerReadiness)
+	handler.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	handler.HandleFunc("GET /.well-known/oauth-authorization-server", apiCfg.handlerOAuthMetadata)
 
 	handler.HandleFunc("GET /api/chirps", apiCfg.handlerGetPosts)
 	handler.HandleFunc("POST /api/chirps", apiCfg.handlerCreatePost)
 	handler.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeletePost)
 	handler.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetPost)
+	handler.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.handlerPinPost)
+	handler.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.handlerUnpinPost)
+	handler.HandleFunc("PUT /api/chirps/{chirpID}/expiry", apiCfg.handlerSetPostExpiry)
+	handler.HandleFunc("DELETE /api/chirps/{chirpID}/expiry", apiCfg.handlerClearPostExpiry)
 
 	handler.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	handler.HandleFunc("PUT /api/users", apiCfg.handlerUpdateEmailAndPassword)
+	handler.HandleFunc("PUT /api/users/email", apiCfg.handlerChangeEmail)
+	handler.HandleFunc("PUT /api/users/password", apiCfg.handlerChangePassword)
+	handler.HandleFunc("DELETE /api/users/me", apiCfg.handlerDeleteAccount)
+	handler.HandleFunc("POST /api/users/me/export", apiCfg.handlerStartExport)
+	handler.HandleFunc("GET /api/users/me/export", apiCfg.handlerGetLatestExport)
+	handler.HandleFunc("GET /api/users/me/export/{exportID}", apiCfg.handlerGetExport)
+	handler.HandleFunc("GET /api/exports/{exportID}/download", apiCfg.handlerDownloadExport)
+	handler.HandleFunc("POST /api/users/me/import", apiCfg.handlerImport)
+	handler.HandleFunc("GET /api/users/me/import/{importID}", apiCfg.handlerGetImport)
+	handler.HandleFunc("GET /api/users/verify", apiCfg.handlerVerifyEmail)
+	handler.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	handler.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerResendVerification)
+	handler.HandleFunc("POST /api/users/2fa", apiCfg.handlerEnrollTOTP)
+	handler.HandleFunc("POST /api/users/2fa/confirm", apiCfg.handlerConfirmTOTP)
+	handler.HandleFunc("GET /api/users/me/identities", apiCfg.handlerGetIdentities)
+	handler.HandleFunc("POST /api/users/me/identities/{provider}", apiCfg.handlerLinkIdentity)
+	handler.HandleFunc("DELETE /api/users/me/identities/{identityID}", apiCfg.handlerUnlinkIdentity)
+	handler.HandleFunc("POST /api/identities/confirm", apiCfg.handlerConfirmIdentityLink)
+	handler.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	handler.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
 
 	handler.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	handler.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTOTP)
+	handler.HandleFunc("GET /api/oidc/{provider}/login", apiCfg.handlerOIDCLogin)
+	handler.HandleFunc("GET /api/oidc/{provider}/link", apiCfg.handlerOIDCLink)
+	handler.HandleFunc("GET /api/oidc/{provider}/callback", apiCfg.handlerOIDCCallback)
+	handler.HandleFunc("POST /api/password-reset", apiCfg.handlerRequestPasswordReset)
+	handler.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerConfirmPasswordReset)
 	handler.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
 	handler.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeToken)
 
+	handler.HandleFunc("GET /api/sessions", apiCfg.handlerGetSessions)
+	handler.HandleFunc("DELETE /api/sessions", apiCfg.handlerRevokeAllSessions)
+	handler.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerRevokeSession)
+	handler.HandleFunc("POST /api/tokens", apiCfg.handlerCreatePersonalAccessToken)
+	handler.HandleFunc("GET /api/tokens", apiCfg.handlerGetPersonalAccessTokens)
+	handler.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.handlerRevokePersonalAccessToken)
+
+	handler.HandleFunc("POST /api/oauth/clients", apiCfg.handlerCreateOAuthClient)
+	handler.HandleFunc("GET /api/oauth/clients", apiCfg.handlerGetOAuthClients)
+	handler.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerDeleteOAuthClient)
+
+	// OAuth2 authorization server
+	handler.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	handler.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthConsent)
+	handler.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	handler.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+	handler.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+
 	handler.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
 	handler.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 
 	// webhooks
 	handler.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeRedChirpy)
 
+	go apiCfg.sweepExpiredPosts(time.Minute)
+	go apiCfg.sweepLoginFailures(time.Hour)
+	go apiCfg.sweepPasswordResetRequests(time.Hour)
+	go apiCfg.sendPasswordResets()
+	go apiCfg.sweepFinishedMail(time.Hour)
+	go apiCfg.sweepDeletedUsers(time.Hour)
+	go apiCfg.sweepOIDCLoginStates(time.Hour)
+	go apiCfg.sweepOAuthAuthorizationCodes(time.Hour)
+	go apiCfg.runExportJobs(5 * time.Second)
+	go apiCfg.runImportJobs(5 * time.Second)
+	go outbox.Run(10 * time.Second)
+
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: handler,
@@ -102,3 +381,159 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// envInt reads an integer setting from the environment, falling back to
+// def when it is unset.
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %s", key, err)
+	}
+	return n
+}
+
+// envDuration reads a duration like "720h" from the environment, falling
+// back to def when it is unset.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration: %s", key, err)
+	}
+	return d
+}
+
+// envBool reads a setting like "true" from the environment, falling back
+// to def when it is unset.
+func envBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s must be true or false: %s", key, err)
+	}
+	return b
+}
+
+// loadMailer picks how mail is delivered from MAILER: "smtp", "file" to
+// drop .eml files into MAIL_DIR, or "log" to only log it, which is the
+// default for development.
+func loadMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "", "log":
+		return mailer.LogMailer{}, nil
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			addr = "localhost:1025"
+		}
+		return mailer.SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			return nil, errors.New("MAIL_DIR must be set for the file mailer")
+		}
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+		return mailer.FileMailer{Dir: dir, From: from}, nil
+	case "memory":
+		return &mailer.MemoryMailer{}, nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", os.Getenv("MAILER"))
+}
+
+// loadOIDCProviders sets up the identity providers named in
+// OIDC_PROVIDERS, a comma separated list. Each provider is configured with
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+// optionally OIDC_<NAME>_SCOPES, and is registered with the redirect URI
+// BASE_URL/api/oidc/<name>/callback.
+func loadOIDCProviders() (map[string]*oidc.Provider, error) {
+	providers := map[string]*oidc.Provider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oidc.Config{
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(strings.ReplaceAll(os.Getenv(prefix+"SCOPES"), ",", " ")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+
+		providers[name] = oidc.NewProvider(name, config)
+	}
+	return providers, nil
+}
+
+// loadJWTKeys signs access tokens with the key in JWT_SIGNING_KEY and also
+// accepts the keys in JWT_VERIFICATION_KEYS, so a key can be rotated out
+// without invalidating the tokens it signed. Without a signing key, tokens
+// are signed with the HMAC secret.
+//
+// Moving from the secret to a signing key logs everyone out, unless
+// JWT_ACCEPT_LEGACY_HMAC=true keeps accepting tokens signed with the
+// secret. Unset it once those tokens have expired, anyone who knows the
+// secret can mint tokens for as long as it is accepted.
+func loadJWTKeys(secret string) (*auth.KeySet, error) {
+	signingKeyPath := os.Getenv("JWT_SIGNING_KEY")
+	if signingKeyPath == "" {
+		return auth.NewHMACKeySet(secret), nil
+	}
+
+	signingKey, err := auth.LoadKeyFile(signingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var verificationKeys []*auth.Key
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEYS"), ",") {
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		key, err := auth.LoadKeyFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	if envBool("JWT_ACCEPT_LEGACY_HMAC", false) {
+		if secret == "" {
+			return nil, errors.New("SECRET must be set to accept tokens signed with it")
+		}
+		log.Printf("Accepting tokens signed with SECRET, unset JWT_ACCEPT_LEGACY_HMAC once they have expired")
+		verificationKeys = append(verificationKeys, auth.NewHMACKey(secret))
+	}
+
+	return auth.NewKeySet(signingKey, verificationKeys...)
+}
@@ -0,0 +1,18 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (id, created_at, user_id, email, token_hash, expires_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    Now() + INTERVAL '24 hours'
+)
+RETURNING *;
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens SET used_at = Now()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > Now()
+RETURNING *;
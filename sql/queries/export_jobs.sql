@@ -0,0 +1,75 @@
+-- name: CreateExportJob :one
+INSERT INTO export_jobs (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    'pending'
+)
+RETURNING *;
+
+-- name: GetExportJob :one
+SELECT * FROM export_jobs
+WHERE id = $1
+AND user_id = $2;
+
+-- name: GetLatestExportJobOfUser :one
+SELECT * FROM export_jobs
+WHERE user_id = $1
+AND status <> 'failed'
+AND (expires_at IS NULL OR expires_at > Now())
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetUnfinishedExportJobOfUser :one
+SELECT * FROM export_jobs
+WHERE user_id = $1
+AND status IN ('pending', 'running')
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimExportJob :one
+-- A running job that hasn't been touched for a while was left behind by a
+-- crashed instance and is picked up again.
+UPDATE export_jobs SET status = 'running',
+updated_at = Now()
+WHERE id = (
+    SELECT id FROM export_jobs
+    WHERE status = 'pending'
+    OR (status = 'running' AND updated_at < Now() - INTERVAL '10 minutes')
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreateExportArchive :exec
+INSERT INTO export_archives (export_job_id, data)
+VALUES ($1, $2)
+ON CONFLICT (export_job_id) DO UPDATE SET data = EXCLUDED.data;
+
+-- name: CompleteExportJob :exec
+UPDATE export_jobs SET status = 'done',
+completed_at = Now(),
+expires_at = Now() + make_interval(secs => sqlc.arg(expires_in_seconds)::float8),
+updated_at = Now()
+WHERE id = sqlc.arg(id);
+
+-- name: FailExportJob :exec
+UPDATE export_jobs SET status = 'failed',
+error = $2,
+updated_at = Now()
+WHERE id = $1;
+
+-- name: GetExportArchive :one
+SELECT export_archives.data FROM export_archives
+JOIN export_jobs ON export_jobs.id = export_archives.export_job_id
+WHERE export_jobs.id = $1
+AND export_jobs.status = 'done'
+AND export_jobs.expires_at > Now();
+
+-- name: DeleteExpiredExportJobs :execrows
+DELETE FROM export_jobs
+WHERE expires_at <= Now()
+OR (status = 'failed' AND updated_at < Now() - INTERVAL '7 days');
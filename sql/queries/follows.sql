@@ -0,0 +1,19 @@
+-- name: CreateFollow :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, Now())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :exec
+DELETE FROM follows
+WHERE follower_id = $1
+AND followee_id = $2;
+
+-- name: GetFolloweesOfUser :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetFollowersOfUser :many
+SELECT * FROM follows
+WHERE followee_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,84 @@
+-- name: CreateImportJob :one
+INSERT INTO import_jobs (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    'pending'
+)
+RETURNING *;
+
+-- name: CreateImportUpload :exec
+INSERT INTO import_uploads (import_job_id, data)
+VALUES ($1, $2);
+
+-- name: GetImportJob :one
+SELECT * FROM import_jobs
+WHERE id = $1
+AND user_id = $2;
+
+-- name: ClaimImportJob :one
+-- A running job that hasn't been touched for a while was left behind by a
+-- crashed instance and is picked up again.
+UPDATE import_jobs SET status = 'running',
+updated_at = Now()
+WHERE id = (
+    SELECT id FROM import_jobs
+    WHERE status = 'pending'
+    OR (status = 'running' AND updated_at < Now() - INTERVAL '10 minutes')
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: GetImportUpload :one
+SELECT data FROM import_uploads
+WHERE import_job_id = $1;
+
+-- name: CountChirpsImportedByUserInLastDay :one
+SELECT COALESCE(SUM(imported), 0)::bigint AS imported FROM import_jobs
+WHERE user_id = $1
+AND status = 'done'
+AND completed_at > Now() - INTERVAL '1 day';
+
+-- name: CompleteImportJob :exec
+UPDATE import_jobs SET status = 'done',
+total = $2,
+imported = $3,
+skipped = $4,
+failed = $5,
+completed_at = Now(),
+updated_at = Now()
+WHERE id = $1;
+
+-- name: FailImportJob :exec
+UPDATE import_jobs SET status = 'failed',
+error = $2,
+updated_at = Now()
+WHERE id = $1;
+
+-- name: DeleteImportUpload :exec
+DELETE FROM import_uploads
+WHERE import_job_id = $1;
+
+-- name: CreateImportItemError :exec
+INSERT INTO import_item_errors (import_job_id, item, message)
+VALUES ($1, $2, $3)
+ON CONFLICT (import_job_id, item) DO UPDATE SET message = EXCLUDED.message;
+
+-- name: GetImportItemErrors :many
+SELECT item, message FROM import_item_errors
+WHERE import_job_id = $1
+ORDER BY item;
+
+-- name: DeleteOldImportJobs :execrows
+DELETE FROM import_jobs
+WHERE updated_at < Now() - INTERVAL '30 days'
+AND status IN ('done', 'failed');
+
+-- name: GetImportJobsOfUser :many
+SELECT * FROM import_jobs
+WHERE user_id = $1
+ORDER BY created_at;
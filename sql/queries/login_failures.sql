@@ -0,0 +1,46 @@
+-- name: CreateLoginFailure :exec
+INSERT INTO login_failures (id, created_at, email, ip_address)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2
+);
+
+-- name: GetLoginFailuresOfEmail :one
+SELECT COUNT(*) AS failures,
+COALESCE(date_part('epoch', Now() - MAX(created_at)), 0)::float8 AS seconds_since_last
+FROM login_failures
+WHERE email = sqlc.arg(email)
+AND created_at > Now() - make_interval(secs => sqlc.arg(window_seconds)::float8);
+
+-- name: GetLoginFailuresOfIP :one
+SELECT COUNT(*) AS failures,
+COALESCE(date_part('epoch', Now() - MAX(created_at)), 0)::float8 AS seconds_since_last
+FROM login_failures
+WHERE ip_address = sqlc.arg(ip_address)
+AND created_at > Now() - make_interval(secs => sqlc.arg(window_seconds)::float8);
+
+-- name: DeleteLoginFailuresOfEmail :exec
+DELETE FROM login_failures
+WHERE email = $1;
+
+-- name: DeleteLoginFailuresBefore :execrows
+DELETE FROM login_failures
+WHERE created_at < Now() - make_interval(secs => sqlc.arg(age_seconds)::float8);
+
+-- name: CreateMFAFailure :exec
+INSERT INTO mfa_failures (id, created_at, token_id)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1
+);
+
+-- name: CountMFAFailuresOfToken :one
+SELECT COUNT(*) AS failures FROM mfa_failures
+WHERE token_id = $1;
+
+-- name: DeleteMFAFailuresBefore :execrows
+DELETE FROM mfa_failures
+WHERE created_at < Now() - make_interval(secs => sqlc.arg(age_seconds)::float8);
@@ -0,0 +1,132 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: GetOAuthClientsOfOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at;
+
+-- name: DeleteOAuthClient :one
+DELETE FROM oauth_clients
+WHERE id = $1
+AND owner_id = $2
+RETURNING *;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+    $1,
+    Now(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    Now() + INTERVAL '1 minute'
+);
+
+-- name: UseOAuthAuthorizationCode :one
+-- A code can only be exchanged once. Used codes are kept a while to catch
+-- replays.
+UPDATE oauth_authorization_codes SET used_at = Now()
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > Now()
+RETURNING *;
+
+-- name: SetOAuthAuthorizationCodeGrant :exec
+UPDATE oauth_authorization_codes SET grant_id = $2
+WHERE code_hash = $1;
+
+-- name: GetUsedOAuthAuthorizationCode :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1
+AND used_at IS NOT NULL;
+
+-- name: DeleteExpiredOAuthAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE expires_at <= Now() - INTERVAL '1 day';
+
+-- name: CreateOAuthGrant :one
+INSERT INTO oauth_grants (id, created_at, updated_at, client_id, user_id, scopes, refresh_token_hash, last_used_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    $4,
+    Now()
+)
+RETURNING *;
+
+-- name: GetActiveOAuthGrant :one
+SELECT * FROM oauth_grants
+WHERE id = $1
+AND revoked_at IS NULL;
+
+-- name: GetActiveOAuthGrantWithRefreshToken :one
+SELECT * FROM oauth_grants
+WHERE refresh_token_hash = $1
+AND revoked_at IS NULL;
+
+-- name: RotateOAuthRefreshToken :one
+-- Refreshing replaces the refresh token, the old one stops working.
+UPDATE oauth_grants SET refresh_token_hash = sqlc.arg(new_hash),
+last_used_at = Now(),
+updated_at = Now()
+WHERE refresh_token_hash = sqlc.arg(old_hash)
+AND revoked_at IS NULL
+RETURNING *;
+
+-- name: CreateOAuthUsedRefreshToken :exec
+INSERT INTO oauth_used_refresh_tokens (token_hash, created_at, grant_id)
+VALUES (
+    $1,
+    Now(),
+    $2
+);
+
+-- name: GetOAuthUsedRefreshToken :one
+SELECT * FROM oauth_used_refresh_tokens
+WHERE token_hash = $1;
+
+-- name: DeleteOAuthUsedRefreshTokensOfRevokedGrants :execrows
+-- Once a grant is revoked, a replay of its old tokens has nothing left to
+-- revoke.
+DELETE FROM oauth_used_refresh_tokens
+USING oauth_grants
+WHERE oauth_grants.id = oauth_used_refresh_tokens.grant_id
+AND oauth_grants.revoked_at IS NOT NULL;
+
+-- name: RevokeOAuthGrant :exec
+UPDATE oauth_grants SET revoked_at = Now(),
+updated_at = Now()
+WHERE id = $1
+AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantsOfUser :exec
+UPDATE oauth_grants SET revoked_at = Now(),
+updated_at = Now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetOAuthGrantsOfUser :many
+SELECT * FROM oauth_grants
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,59 @@
+-- name: CreateOutboxMessage :one
+INSERT INTO outbox_messages (id, created_at, updated_at, to_address, subject, text_body, html_body, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    $4,
+    Now()
+)
+RETURNING *;
+
+-- name: ClaimOutboxMessages :many
+-- Claimed messages aren't due again until the lease runs out, so a crash
+-- mid-delivery only delays them and other instances skip them meanwhile.
+UPDATE outbox_messages SET attempts = attempts + 1,
+next_attempt_at = Now() + make_interval(secs => sqlc.arg(lease_seconds)::float8),
+updated_at = Now()
+WHERE id IN (
+    SELECT id FROM outbox_messages
+    WHERE sent_at IS NULL
+    AND failed_at IS NULL
+    AND next_attempt_at <= Now()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxMessageSent :exec
+-- The bodies are cleared once they are no longer needed, they hold live
+-- links for verifying emails and resetting passwords.
+UPDATE outbox_messages SET sent_at = Now(),
+last_error = NULL,
+text_body = '',
+html_body = '',
+updated_at = Now()
+WHERE id = $1;
+
+-- name: RetryOutboxMessage :exec
+UPDATE outbox_messages SET last_error = sqlc.narg(last_error),
+next_attempt_at = Now() + make_interval(secs => sqlc.arg(retry_in_seconds)::float8),
+updated_at = Now()
+WHERE id = sqlc.arg(id);
+
+-- name: FailOutboxMessage :exec
+UPDATE outbox_messages SET last_error = $2,
+failed_at = Now(),
+text_body = '',
+html_body = '',
+updated_at = Now()
+WHERE id = $1;
+
+-- name: DeleteFinishedOutboxMessagesBefore :execrows
+DELETE FROM outbox_messages
+WHERE sent_at < Now() - make_interval(secs => sqlc.arg(age_seconds)::float8)
+OR failed_at < Now() - make_interval(secs => sqlc.arg(age_seconds)::float8);
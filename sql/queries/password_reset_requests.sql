@@ -0,0 +1,26 @@
+-- name: CreatePasswordResetRequest :exec
+INSERT INTO password_reset_requests (id, created_at, email, ip_address)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2
+);
+
+-- name: GetPasswordResetRequestsOfEmail :one
+SELECT COUNT(*) AS requests,
+COALESCE(date_part('epoch', Now() - MIN(created_at)), 0)::float8 AS seconds_since_first
+FROM password_reset_requests
+WHERE email = sqlc.arg(email)
+AND created_at > Now() - make_interval(secs => sqlc.arg(window_seconds)::float8);
+
+-- name: GetPasswordResetRequestsOfIP :one
+SELECT COUNT(*) AS requests,
+COALESCE(date_part('epoch', Now() - MIN(created_at)), 0)::float8 AS seconds_since_first
+FROM password_reset_requests
+WHERE ip_address = sqlc.arg(ip_address)
+AND created_at > Now() - make_interval(secs => sqlc.arg(window_seconds)::float8);
+
+-- name: DeletePasswordResetRequestsBefore :execrows
+DELETE FROM password_reset_requests
+WHERE created_at < Now() - make_interval(secs => sqlc.arg(age_seconds)::float8);
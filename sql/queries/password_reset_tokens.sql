@@ -0,0 +1,22 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (id, created_at, user_id, token_hash, expires_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2,
+    Now() + INTERVAL '30 minutes'
+)
+RETURNING *;
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens SET used_at = Now()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > Now()
+RETURNING *;
+
+-- name: UsePasswordResetTokensOfUser :exec
+UPDATE password_reset_tokens SET used_at = Now()
+WHERE user_id = $1
+AND used_at IS NULL;
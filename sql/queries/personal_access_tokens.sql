@@ -0,0 +1,49 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    $4,
+    Now() + make_interval(secs => sqlc.narg(expires_in_seconds)::float8)
+)
+RETURNING *;
+
+-- name: GetActivePersonalAccessToken :one
+SELECT * FROM personal_access_tokens
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > Now());
+
+-- name: GetActivePersonalAccessTokensOfUser :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > Now())
+ORDER BY created_at DESC;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens SET last_used_at = Now()
+WHERE id = $1;
+
+-- name: RevokePersonalAccessToken :one
+UPDATE personal_access_tokens SET revoked_at = Now(),
+updated_at = Now()
+WHERE id = $1
+AND user_id = $2
+AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RevokePersonalAccessTokensOfUser :exec
+UPDATE personal_access_tokens SET revoked_at = Now(),
+updated_at = Now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetPersonalAccessTokensOfUser :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -1,27 +1,160 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, body, user_id)
+INSERT INTO posts (id, created_at, updated_at, body, user_id, visibility, expires_at)
 VALUES (
     gen_random_uuid(),
     Now(),
     Now(),
     $1,
-    $2
+    $2,
+    $3,
+    Now() + make_interval(secs => sqlc.narg(expires_in_seconds)::float8)
 )
 RETURNING *;
 
 -- name: GetPosts :many
 SELECT * FROM posts
+WHERE (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = sqlc.arg(viewer_id)
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = sqlc.arg(viewer_id)
+    ))
+)
 ORDER BY created_at ASC;
 
 -- name: GetPostsOfAuthor :many
 SELECT * FROM posts
-WHERE user_id = $1
+WHERE user_id = sqlc.arg(user_id)
+AND (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = sqlc.arg(viewer_id)
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = sqlc.arg(viewer_id)
+    ))
+)
 ORDER BY created_at ASC;
 
 -- name: GetPost :one
 SELECT * FROM posts
 WHERE id = $1;
 
+-- name: GetUnexpiredPost :one
+SELECT * FROM posts
+WHERE id = $1
+AND (expires_at IS NULL OR expires_at > Now());
+
+-- name: GetVisiblePost :one
+SELECT * FROM posts
+WHERE id = sqlc.arg(id)
+AND (expires_at IS NULL OR expires_at > Now())
+AND (
+    visibility = 'public'
+    OR user_id = sqlc.arg(viewer_id)
+    OR (visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.followee_id = posts.user_id
+    ))
+    OR (visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM post_mentions
+        WHERE post_mentions.post_id = posts.id
+        AND post_mentions.user_id = sqlc.arg(viewer_id)
+    ))
+);
+
 -- name: DeletePost :exec
 DELETE FROM posts
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: CreatePostMention :exec
+INSERT INTO post_mentions (post_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: PinPost :one
+UPDATE posts SET pinned_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnpinPost :one
+UPDATE posts SET pinned_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: CountPinnedPostsOfAuthor :one
+SELECT COUNT(*) FROM posts
+WHERE user_id = $1
+AND pinned_at IS NOT NULL
+AND (expires_at IS NULL OR expires_at > Now());
+
+-- name: CountPostsOfAuthorInLastHour :one
+SELECT COUNT(*) FROM posts
+WHERE user_id = $1
+AND created_at > Now() - INTERVAL '1 hour';
+
+-- name: SetPostExpiry :one
+UPDATE posts SET expires_at = Now() + make_interval(secs => sqlc.arg(expires_in_seconds)::float8),
+updated_at = Now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ClearPostExpiry :one
+UPDATE posts SET expires_at = NULL,
+updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteExpiredPosts :execrows
+DELETE FROM posts
+WHERE expires_at <= Now();
+
+-- name: GetAllPostsOfAuthor :many
+SELECT * FROM posts
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ImportPost :one
+-- Chirps already imported under the same key are skipped, no row is
+-- returned for them.
+INSERT INTO posts (id, created_at, updated_at, body, user_id, visibility, expires_at, import_key)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (user_id, import_key) DO NOTHING
+RETURNING *;
+
+-- name: GetMentionsInPostsOfAuthor :many
+SELECT post_mentions.post_id, post_mentions.user_id FROM post_mentions
+JOIN posts ON posts.id = post_mentions.post_id
+WHERE posts.user_id = $1;
+
+-- name: GetMentionsOfUser :many
+-- Chirps by others that mention the user.
+SELECT post_mentions.post_id, posts.user_id AS author_id FROM post_mentions
+JOIN posts ON posts.id = post_mentions.post_id
+WHERE post_mentions.user_id = $1
+AND posts.user_id <> $1
+ORDER BY posts.created_at;
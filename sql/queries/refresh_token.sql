@@ -1,23 +1,41 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, family_id)
 VALUES (
     $1,
     Now(),
     Now(),
     $2,
-    Now() + INTERVAL '60 days'
+    Now() + INTERVAL '60 days',
+    $3
 )
 RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT users.* FROM users
-JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1
-AND revoked_at IS NULL
-AND expires_at > NOW();
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
 
 -- name: RevokeRefreshToken :one
 UPDATE refresh_tokens SET revoked_at = Now(),
 updated_at = Now()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+WHERE token_hash = $1
+RETURNING *;
+
+-- name: RevokeActiveRefreshToken :one
+UPDATE refresh_tokens SET revoked_at = Now(),
+updated_at = Now()
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND expires_at > Now()
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET revoked_at = Now(),
+updated_at = Now()
+WHERE family_id = $1
+AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokensOfUser :exec
+UPDATE refresh_tokens SET revoked_at = Now(),
+updated_at = Now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
@@ -0,0 +1,47 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, updated_at, user_id, ip_address, user_agent, last_used_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    Now()
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_used_at = Now(),
+updated_at = Now()
+WHERE id = $1;
+
+-- name: GetActiveSessionsOfUser :many
+SELECT * FROM sessions
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE refresh_tokens.family_id = sessions.id
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > Now()
+)
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :one
+UPDATE sessions SET revoked_at = Now(),
+updated_at = Now()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: RevokeSessionsOfUser :exec
+UPDATE sessions SET revoked_at = Now(),
+updated_at = Now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetSessionsOfUser :many
+SELECT * FROM sessions
+WHERE user_id = $1
+ORDER BY created_at ASC;
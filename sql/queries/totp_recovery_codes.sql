@@ -0,0 +1,19 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO totp_recovery_codes (id, created_at, user_id, code_hash)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2
+);
+
+-- name: UseRecoveryCode :one
+UPDATE totp_recovery_codes SET used_at = Now()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodesOfUser :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
@@ -0,0 +1,62 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, user_id, provider, issuer, subject, email, last_login_at)
+VALUES (
+    gen_random_uuid(),
+    Now(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    Now()
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1
+AND subject = $2;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities SET last_login_at = Now(),
+email = $2
+WHERE id = $1;
+
+-- name: GetUserIdentitiesOfUser :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteUserIdentity :one
+DELETE FROM user_identities
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state_hash, created_at, provider, nonce, code_verifier, user_id, expires_at)
+VALUES (
+    $1,
+    Now(),
+    $2,
+    $3,
+    $4,
+    $5,
+    Now() + INTERVAL '10 minutes'
+);
+
+-- name: GetOIDCLoginState :one
+SELECT * FROM oidc_login_states
+WHERE state_hash = $1
+AND expires_at > Now();
+
+-- name: UseOIDCLoginState :one
+-- A state can only be used once.
+DELETE FROM oidc_login_states
+WHERE state_hash = $1
+AND expires_at > Now()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :execrows
+DELETE FROM oidc_login_states
+WHERE expires_at <= Now();
@@ -16,12 +16,81 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdateUserEmailAndPassword :one
-UPDATE users SET email = $2, hashed_password = $3
+-- name: UpdateUserEmail :one
+UPDATE users SET email = $2,
+email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users SET hashed_password = $2,
+updated_at = Now()
 WHERE id = $1
 RETURNING *;
 
 -- name: UpgradeIsChirpyRed :one
 UPDATE users SET is_chirpy_red = TRUE
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserForUpdate :one
+-- Locks the user until the transaction ends, for checks of limits on what
+-- they own that mustn't race.
+SELECT * FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: IncrementTokenVersion :one
+UPDATE users SET token_version = token_version + 1
+WHERE id = $1
+RETURNING *;
+
+-- name: SetTOTPSecret :one
+UPDATE users SET totp_secret = $2, totp_enabled_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableTOTP :one
+UPDATE users SET totp_enabled_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UseTOTPStep :one
+UPDATE users SET totp_last_step = $2
+WHERE id = $1
+AND totp_last_step < $2
+RETURNING *;
+
+-- name: SetUserPasswordHash :exec
+UPDATE users SET hashed_password = $2
+WHERE id = $1;
+
+-- name: VerifyUserEmail :one
+UPDATE users SET email_verified_at = Now()
+WHERE id = $1
+AND email = $2
+RETURNING *;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users SET deletion_scheduled_at = Now() + make_interval(secs => sqlc.arg(grace_seconds)::float8),
+updated_at = Now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CancelUserDeletion :one
+UPDATE users SET deletion_scheduled_at = NULL,
+updated_at = Now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteScheduledUsers :many
+-- Everything a user owns references them with ON DELETE CASCADE, so this
+-- removes their chirps, tokens, sessions, follows and mentions too.
+DELETE FROM users
+WHERE deletion_scheduled_at <= Now()
+RETURNING email;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD visibility TEXT NOT NULL DEFAULT 'public';
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN visibility;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE post_mentions (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE post_mentions;
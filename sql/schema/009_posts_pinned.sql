@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD pinned_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN pinned_at;
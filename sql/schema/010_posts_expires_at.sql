@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN expires_at;
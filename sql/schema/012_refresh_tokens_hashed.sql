@@ -0,0 +1,13 @@
+-- +goose Up
+-- Raw tokens can't be rehashed here because the hashing key only lives in
+-- the application, so every existing session is signed out instead.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+-- +goose Down
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
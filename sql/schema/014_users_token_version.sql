@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD token_version INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN token_version;
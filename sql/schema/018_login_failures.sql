@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE login_failures (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL
+);
+
+CREATE INDEX login_failures_email_idx ON login_failures (email, created_at);
+CREATE INDEX login_failures_ip_address_idx ON login_failures (ip_address, created_at);
+
+-- +goose Down
+DROP TABLE login_failures;
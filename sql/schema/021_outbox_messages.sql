@@ -0,0 +1,23 @@
+-- +goose Up
+-- Mail waits here until it's delivered, so it survives restarts and
+-- failing mail servers.
+CREATE TABLE outbox_messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    to_address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    failed_at TIMESTAMP
+);
+
+CREATE INDEX outbox_messages_pending_idx ON outbox_messages (next_attempt_at)
+WHERE sent_at IS NULL AND failed_at IS NULL;
+
+-- +goose Down
+DROP TABLE outbox_messages;
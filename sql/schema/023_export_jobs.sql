@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE export_jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    error TEXT,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+-- Archives are kept apart so listing jobs doesn't drag them along.
+CREATE TABLE export_archives (
+    export_job_id UUID PRIMARY KEY REFERENCES export_jobs(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL
+);
+
+-- +goose Down
+DROP TABLE export_archives;
+
+DROP TABLE export_jobs;
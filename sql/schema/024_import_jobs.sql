@@ -0,0 +1,45 @@
+-- +goose Up
+-- import_key identifies where an imported chirp came from, importing the
+-- same chirp again is skipped.
+ALTER TABLE posts
+ADD COLUMN import_key TEXT;
+
+ALTER TABLE posts
+ADD CONSTRAINT posts_user_id_import_key_key UNIQUE (user_id, import_key);
+
+CREATE TABLE import_jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    error TEXT,
+    total INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP
+);
+
+-- Uploads are only needed until the job has run.
+CREATE TABLE import_uploads (
+    import_job_id UUID PRIMARY KEY REFERENCES import_jobs(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL
+);
+
+CREATE TABLE import_item_errors (
+    import_job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    item INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    PRIMARY KEY (import_job_id, item)
+);
+
+-- +goose Down
+DROP TABLE import_item_errors;
+
+DROP TABLE import_uploads;
+
+DROP TABLE import_jobs;
+
+ALTER TABLE posts
+DROP COLUMN import_key;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Requested password resets are kept for a while so they can be throttled
+-- per email and per IP address, whether or not an account has the email.
+CREATE TABLE password_reset_requests (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL
+);
+
+CREATE INDEX password_reset_requests_email_idx ON password_reset_requests (email, created_at);
+CREATE INDEX password_reset_requests_ip_address_idx ON password_reset_requests (ip_address, created_at);
+
+-- +goose Down
+DROP TABLE password_reset_requests;
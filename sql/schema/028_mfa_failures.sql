@@ -0,0 +1,13 @@
+-- +goose Up
+-- Wrong second-factor codes are counted per mfa token, by its jti, so each
+-- token gets its few guesses no matter what else fails for the account.
+CREATE TABLE mfa_failures (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    token_id UUID NOT NULL
+);
+
+CREATE INDEX mfa_failures_token_id_idx ON mfa_failures (token_id);
+
+-- +goose Down
+DROP TABLE mfa_failures;
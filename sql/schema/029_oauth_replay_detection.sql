@@ -0,0 +1,19 @@
+-- +goose Up
+-- Exchanged authorization codes and rotated-out refresh tokens are kept, so
+-- a replay of one can be caught and the grant it led to revoked.
+ALTER TABLE oauth_authorization_codes
+ADD COLUMN used_at TIMESTAMP,
+ADD COLUMN grant_id UUID REFERENCES oauth_grants(id) ON DELETE CASCADE;
+
+CREATE TABLE oauth_used_refresh_tokens (
+    token_hash TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    grant_id UUID NOT NULL REFERENCES oauth_grants(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE oauth_used_refresh_tokens;
+
+ALTER TABLE oauth_authorization_codes
+DROP COLUMN grant_id,
+DROP COLUMN used_at;
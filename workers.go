@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// sweepExpiredPosts deletes expired chirps every interval. Listings already
+// hide them, this only keeps them from piling up in the database.
+func (cfg *apiConfig) sweepExpiredPosts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := cfg.db.DeleteExpiredPosts(context.Background())
+		if err != nil {
+			log.Printf("Couldn't delete expired chirps: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d expired chirps", deleted)
+		}
+	}
+}
+
+// sweepLoginFailures deletes failed logins every interval once they are too
+// old to count against anyone.
+func (cfg *apiConfig) sweepLoginFailures(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	window := max(cfg.accountLoginPolicy.Window, cfg.ipLoginPolicy.Window)
+	for range ticker.C {
+		deleted, err := cfg.db.DeleteLoginFailuresBefore(context.Background(), window.Seconds())
+		if err != nil {
+			log.Printf("Couldn't delete old failed logins: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d old failed logins", deleted)
+		}
+
+		// wrong codes only count against their mfa token, which is long
+		// expired by now
+		_, err = cfg.db.DeleteMFAFailuresBefore(context.Background(), mfaTokenLifetime.Seconds())
+		if err != nil {
+			log.Printf("Couldn't delete old failed codes: %s", err)
+		}
+	}
+}
+
+// sweepPasswordResetRequests deletes requested password resets every
+// interval once they are too old to count against anyone.
+func (cfg *apiConfig) sweepPasswordResetRequests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := cfg.db.DeletePasswordResetRequestsBefore(context.Background(), passwordResetWindow.Seconds())
+		if err != nil {
+			log.Printf("Couldn't delete old password reset requests: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d old password reset requests", deleted)
+		}
+	}
+}
+
+// sendPasswordResets sends the queued password resets one at a time, each
+// with its own deadline so a slow database can't stall the queue.
+func (cfg *apiConfig) sendPasswordResets() {
+	for reset := range cfg.passwordResets {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+		err := cfg.requestPasswordReset(ctx, reset.email, reset.ip)
+		cancel()
+		if err != nil {
+			log.Printf("Couldn't send password reset: %s", err)
+		}
+	}
+}
+
+// sweepFinishedMail deletes mail the outbox delivered or gave up on every
+// interval once it's a week old. Their bodies are already gone, only who
+// they went to and why they failed is kept until then.
+func (cfg *apiConfig) sweepFinishedMail(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := cfg.db.DeleteFinishedOutboxMessagesBefore(context.Background(), (7 * 24 * time.Hour).Seconds())
+		if err != nil {
+			log.Printf("Couldn't delete finished mail: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d finished mails", deleted)
+		}
+	}
+}
+
+// sweepDeletedUsers deletes every interval the accounts whose deletion
+// grace period is over, along with everything they own.
+func (cfg *apiConfig) sweepDeletedUsers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		emails, err := cfg.db.DeleteScheduledUsers(context.Background())
+		if err != nil {
+			log.Printf("Couldn't delete scheduled accounts: %s", err)
+			continue
+		}
+
+		// failed logins are kept by email, not tied to the account
+		for _, email := range emails {
+			err = cfg.clearLoginFailures(context.Background(), email)
+			if err != nil {
+				log.Printf("Couldn't clear failed logins of a deleted account: %s", err)
+			}
+		}
+		if len(emails) > 0 {
+			log.Printf("Deleted %d accounts", len(emails))
+		}
+	}
+}
+
+// runExportJobs builds the archives of pending exports every interval and
+// deletes the ones that have expired.
+func (cfg *apiConfig) runExportJobs(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		for {
+			job, err := cfg.db.ClaimExportJob(ctx)
+			if err == sql.ErrNoRows {
+				break
+			}
+			if err != nil {
+				log.Printf("Couldn't claim export: %s", err)
+				break
+			}
+
+			err = cfg.runExportJob(ctx, job)
+			if err != nil {
+				log.Printf("Couldn't finish export %s: %s", job.ID, err)
+				break
+			}
+		}
+
+		_, err := cfg.db.DeleteExpiredExportJobs(ctx)
+		if err != nil {
+			log.Printf("Couldn't delete expired exports: %s", err)
+		}
+	}
+}
+
+// runImportJobs imports pending uploads every interval and deletes jobs
+// that finished long ago.
+func (cfg *apiConfig) runImportJobs(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		for {
+			job, err := cfg.db.ClaimImportJob(ctx)
+			if err == sql.ErrNoRows {
+				break
+			}
+			if err != nil {
+				log.Printf("Couldn't claim import: %s", err)
+				break
+			}
+
+			err = cfg.runImportJob(ctx, job)
+			if err != nil {
+				log.Printf("Couldn't finish import %s: %s", job.ID, err)
+				break
+			}
+		}
+
+		_, err := cfg.db.DeleteOldImportJobs(ctx)
+		if err != nil {
+			log.Printf("Couldn't delete old imports: %s", err)
+		}
+	}
+}
+
+// sweepOIDCLoginStates deletes sign-ins at identity providers every
+// interval once they can't be finished anymore.
+func (cfg *apiConfig) sweepOIDCLoginStates(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := cfg.db.DeleteExpiredOIDCLoginStates(context.Background())
+		if err != nil {
+			log.Printf("Couldn't delete expired sign-ins: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d expired sign-ins", deleted)
+		}
+	}
+}
+
+// sweepOAuthAuthorizationCodes deletes authorization codes every interval
+// once they have been expired for a day, replays of used ones are caught
+// until then. Rotated-out refresh tokens of revoked grants go too.
+func (cfg *apiConfig) sweepOAuthAuthorizationCodes(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := cfg.db.DeleteExpiredOAuthAuthorizationCodes(context.Background())
+		if err != nil {
+			log.Printf("Couldn't delete expired authorization codes: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d expired authorization codes", deleted)
+		}
+
+		deleted, err = cfg.db.DeleteOAuthUsedRefreshTokensOfRevokedGrants(context.Background())
+		if err != nil {
+			log.Printf("Couldn't delete used refresh tokens: %s", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d used refresh tokens of revoked grants", deleted)
+		}
+	}
+}